@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"ryg-user-service/conf"
+	"ryg-user-service/db"
+	"ryg-user-service/email_address"
+	pbu "ryg-user-service/gen_proto/user_service"
+	"ryg-user-service/logging"
+	"ryg-user-service/password_hash"
+	"ryg-user-service/password_policy"
+	"ryg-user-service/repository"
+	"ryg-user-service/retry_queue"
+	"ryg-user-service/service"
+	"strings"
+	"text/tabwriter"
+)
+
+// newAdminService wires a UserService the same way the server does, so admin
+// commands go through the same hashing and validation. Emails that fail to publish
+// are left in the retry queue for the server to deliver. Commands that don't send
+// messages pass publish false and work while the broker is down.
+func newAdminService(cnf *conf.Config, publish bool) (*service.UserService, func()) {
+	db.ConnectDB(cnf.DB)
+	pubs := disabledPublishers()
+	if publish {
+		pubs = newPublishers(cnf)
+	}
+	users := repository.NewGormUserRepository(db.DB)
+	emailRetryQueue := retry_queue.NewEmailRetryQueue(db.DB, pubs.email, users.EmailSuppressed, cnf.EmailRetry)
+
+	s := service.NewUserService(users, pubs.email, pubs.userCreated, emailRetryQueue, newPasswordPolicy(cnf), password_hash.New(cnf.PasswordHashing), email_address.NewNormalizer(cnf.EmailDomainRules))
+	return s, func() {
+		pubs.close()
+		db.CloseDB()
+	}
+}
+
+func newPasswordPolicy(cnf *conf.Config) *password_policy.Policy {
+	policy, err := password_policy.New(cnf.PasswordPolicy)
+	if err != nil {
+		log.Fatalf("Failed to load password policy: %v", err)
+	}
+	return policy
+}
+
+func runCreateAdmin(cnf *conf.Config, args []string) {
+	fs := flag.NewFlagSet("create-admin", flag.ExitOnError)
+	email := fs.String("email", "", "email of the admin (required)")
+	fullName := fs.String("name", "", "full name of the admin")
+	password := fs.String("password", "", "password; read from stdin when omitted")
+	userLocale := fs.String("locale", "", "BCP 47 locale, e.g. en-US")
+	timezone := fs.String("timezone", "", "IANA time zone, e.g. Asia/Tashkent")
+	_ = fs.Parse(args)
+	requireFlag(fs, "email", *email)
+
+	s, closeService := newAdminService(cnf, true)
+	defer closeService()
+
+	user, err := s.CreateAdmin(context.Background(), &pbu.CreateUserRequest{
+		Email:    *email,
+		FullName: *fullName,
+		Password: passwordOrPrompt(*password),
+		Locale:   *userLocale,
+		Timezone: *timezone,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create admin: %v", err)
+	}
+	printUsers(user)
+}
+
+func runSetRole(cnf *conf.Config, args []string) {
+	fs := flag.NewFlagSet("set-role", flag.ExitOnError)
+	email := fs.String("email", "", "email of the user (required)")
+	role := fs.String("role", "", "admin, user or pro_user (required)")
+	_ = fs.Parse(args)
+	requireFlag(fs, "email", *email)
+	requireFlag(fs, "role", *role)
+
+	s, closeService := newAdminService(cnf, false)
+	defer closeService()
+
+	ctx := context.Background()
+	user, err := s.SetRole(ctx, userIDByEmail(ctx, s, *email), *role)
+	if err != nil {
+		log.Fatalf("Failed to set role: %v", err)
+	}
+	printUsers(user)
+}
+
+func runDeactivate(cnf *conf.Config, args []string) {
+	fs := flag.NewFlagSet("deactivate", flag.ExitOnError)
+	email := fs.String("email", "", "email of the user (required)")
+	_ = fs.Parse(args)
+	requireFlag(fs, "email", *email)
+
+	s, closeService := newAdminService(cnf, false)
+	defer closeService()
+
+	ctx := context.Background()
+	user, err := s.DeactivateUser(ctx, userIDByEmail(ctx, s, *email))
+	if err != nil {
+		log.Fatalf("Failed to deactivate user: %v", err)
+	}
+	printUsers(user)
+}
+
+func runListUsers(cnf *conf.Config, args []string) {
+	fs := flag.NewFlagSet("list-users", flag.ExitOnError)
+	limit := fs.Int("limit", 50, "maximum number of users to list")
+	offset := fs.Int("offset", 0, "number of users to skip")
+	_ = fs.Parse(args)
+
+	s, closeService := newAdminService(cnf, false)
+	defer closeService()
+
+	users, err := s.ListUsers(context.Background(), *limit, *offset)
+	if err != nil {
+		log.Fatalf("Failed to list users: %v", err)
+	}
+	printUsers(users...)
+}
+
+func runResetPassword(cnf *conf.Config, args []string) {
+	fs := flag.NewFlagSet("reset-password", flag.ExitOnError)
+	email := fs.String("email", "", "email of the user (required)")
+	password := fs.String("password", "", "new password; read from stdin when omitted")
+	_ = fs.Parse(args)
+	requireFlag(fs, "email", *email)
+
+	s, closeService := newAdminService(cnf, false)
+	defer closeService()
+
+	ctx := context.Background()
+	user, err := s.ResetPassword(ctx, userIDByEmail(ctx, s, *email), passwordOrPrompt(*password))
+	if err != nil {
+		log.Fatalf("Failed to reset password: %v", err)
+	}
+	printUsers(user)
+}
+
+// importedUser is a line of the import-users input.
+type importedUser struct {
+	Email        string `json:"email"`
+	FullName     string `json:"full_name"`
+	PasswordHash string `json:"password_hash"`
+	Role         string `json:"role"`
+	Locale       string `json:"locale"`
+	Timezone     string `json:"timezone"`
+}
+
+// runImportUsers reads one JSON object per line, e.g.
+// {"email":"jo@example.com","full_name":"Jo","password_hash":"$pbkdf2-sha256$i=260000$<salt>$<key>"},
+// and imports every valid line; the others are reported and left out.
+func runImportUsers(cnf *conf.Config, args []string) {
+	fs := flag.NewFlagSet("import-users", flag.ExitOnError)
+	file := fs.String("file", "", "JSON lines file of users; read from stdin when omitted")
+	_ = fs.Parse(args)
+
+	in := os.Stdin
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			log.Fatalf("Failed to open %s: %v", *file, err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	s, closeService := newAdminService(cnf, false)
+	defer closeService()
+
+	ctx := context.Background()
+	imported, failed := 0, 0
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var u importedUser
+		if err := json.Unmarshal(scanner.Bytes(), &u); err != nil {
+			slog.Error("Invalid JSON", "line", line, "error", err)
+			failed++
+			continue
+		}
+		_, err := s.ImportUser(ctx, service.ImportedUser{
+			Email:        u.Email,
+			FullName:     u.FullName,
+			PasswordHash: u.PasswordHash,
+			Role:         u.Role,
+			Locale:       u.Locale,
+			Timezone:     u.Timezone,
+		})
+		if err != nil {
+			slog.Error("Failed to import user", "line", line, "email", logging.RedactEmail(u.Email), "error", err)
+			failed++
+			continue
+		}
+		imported++
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatalf("Failed to read users: %v", err)
+	}
+
+	slog.Info("Imported users", "imported", imported, "failed", failed)
+	if failed > 0 {
+		closeService()
+		os.Exit(1)
+	}
+}
+
+func runNormalizeEmails(cnf *conf.Config, args []string) {
+	fs := flag.NewFlagSet("normalize-emails", flag.ExitOnError)
+	apply := fs.Bool("apply", false, "update the emails; only list the changes when omitted")
+	_ = fs.Parse(args)
+
+	s, closeService := newAdminService(cnf, false)
+	defer closeService()
+
+	changes, collisions, err := s.NormalizeStoredEmails(context.Background(), *apply)
+	if err != nil {
+		log.Fatalf("Failed to normalize emails: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tEMAIL\tNORMALIZED")
+	for _, change := range changes {
+		fmt.Fprintf(w, "%d\t%s\t%s\n", change.UserID, change.From, change.To)
+	}
+	w.Flush()
+
+	for _, ids := range collisions {
+		slog.Warn("Users have the same normalized email and were left unchanged", "user_ids", ids)
+	}
+	if !*apply && len(changes) > 0 {
+		slog.Info("Run with -apply to update the emails", "count", len(changes))
+	}
+}
+
+func requireFlag(fs *flag.FlagSet, name, value string) {
+	if value == "" {
+		fmt.Fprintf(os.Stderr, "-%s is required\n", name)
+		fs.Usage()
+		os.Exit(2)
+	}
+}
+
+func userIDByEmail(ctx context.Context, s *service.UserService, email string) int64 {
+	user, err := s.GetUserByEmail(ctx, email)
+	if err != nil {
+		log.Fatalf("Failed to find user %s: %v", email, err)
+	}
+	return user.Id
+}
+
+// passwordOrPrompt reads the password from stdin when it wasn't passed as a flag,
+// which keeps it out of the shell history and the process list.
+func passwordOrPrompt(password string) string {
+	if password != "" {
+		return password
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		log.Fatalf("Failed to read password: %v", err)
+	}
+	return strings.TrimRight(line, "\r\n")
+}
+
+func printUsers(users ...*pbu.User) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tEMAIL\tFULL NAME\tROLE\tACTIVE")
+	for _, u := range users {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%t\n", u.Id, u.Email, u.FullName, u.Role, u.IsActive)
+	}
+	w.Flush()
+}
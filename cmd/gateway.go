@@ -0,0 +1,59 @@
+package main
+
+import (
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"ryg-user-service/gateway"
+	pbu "ryg-user-service/gen_proto/user_service"
+	"ryg-user-service/rate_limit"
+	"ryg-user-service/tls_config"
+	"strconv"
+)
+
+// startGateway serves the HTTP/JSON gateway, which calls the gRPC server over a
+// loopback connection so that requests go through the same interceptors. With
+// certs, the gateway serves HTTPS and, like the gRPC listener, requires client
+// certificates when mutual TLS is on.
+func startGateway(addr string, grpcAddr net.Addr, creds credentials.TransportCredentials, certs *tls_config.Reloader, forwarder *rate_limit.TrustedForwarder) (*http.Server, *grpc.ClientConn) {
+	conn, err := grpc.NewClient(loopbackAddr(grpcAddr),
+		grpc.WithTransportCredentials(creds),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+	)
+	if err != nil {
+		log.Fatalf("Failed to connect the gateway to the gRPC server: %v", err)
+	}
+
+	server := &http.Server{Addr: addr, Handler: gateway.NewHandler(pbu.NewUserServiceClient(conn), forwarder)}
+	if certs != nil {
+		server.TLSConfig = certs.HTTPServerConfig()
+	}
+	go func() {
+		var err error
+		if certs != nil {
+			// The certificate comes from TLSConfig.
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to serve the gateway: %v", err)
+		}
+	}()
+	slog.Info("HTTP gateway is running", "address", addr, "tls", certs != nil)
+	return server, conn
+}
+
+// loopbackAddr turns a wildcard listen address, e.g. [::]:50051, into one that
+// can be dialed.
+func loopbackAddr(addr net.Addr) string {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok || !tcpAddr.IP.IsUnspecified() {
+		return addr.String()
+	}
+	return net.JoinHostPort("localhost", strconv.Itoa(tcpAddr.Port))
+}
@@ -2,37 +2,67 @@ package main
 
 import (
 	"fmt"
-	"google.golang.org/grpc"
 	"log"
-	"net"
+	"os"
 	"ryg-user-service/conf"
-	"ryg-user-service/db"
-	"ryg-user-service/gen_proto/user_service"
-	"ryg-user-service/rabbit_mq"
-	"ryg-user-service/service"
+	"ryg-user-service/logging"
 )
 
-func main() {
-	cnf := conf.LoadConfig()
+const usage = `usage: ryg-user-service [command] [flags]
 
-	db.ConnectDB(cnf.DB)
-	defer db.CloseDB()
+commands:
+  serve             run the gRPC server (default)
+  migrate           apply, roll back or list database migrations
+  create-admin      create a user with the admin role
+  set-role          change the role of a user
+  deactivate        deactivate a user
+  list-users        list users
+  reset-password    set a new password for a user
+  import-users      import users with password hashes from another system
+  normalize-emails  apply the email normalization rules to stored emails`
 
-	pm := rabbit_mq.NewPublisherManager(cnf.RabbitMQConfig)
-	defer pm.Close()
+func main() {
+	command, args := "serve", []string(nil)
+	if len(os.Args) > 1 {
+		command, args = os.Args[1], os.Args[2:]
+	}
 
-	lis, err := net.Listen("tcp", cnf.RYGUserServiceUrl)
-	if err != nil {
-		log.Fatalf("Failed to listen: %v", err)
+	if command == "help" || command == "-h" || command == "--help" {
+		fmt.Println(usage)
+		return
 	}
 
-	grpcServer := grpc.NewServer()
+	cnf, err := conf.LoadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	s := service.NewUserService(db.DB, pm.GenericEmailQueuePublisher)
-	user_service.RegisterUserServiceServer(grpcServer, s)
+	level, err := logging.ParseLevel(cnf.LogLevel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	logging.Setup(level)
 
-	fmt.Printf("User Microservice is running on port %v...", cnf.RYGUserServiceUrl)
-	if err := grpcServer.Serve(lis); err != nil {
-		log.Fatalf("Failed to serve: %v", err)
+	switch command {
+	case "serve":
+		runServe(cnf)
+	case "migrate":
+		runMigrate(cnf, args)
+	case "create-admin":
+		runCreateAdmin(cnf, args)
+	case "set-role":
+		runSetRole(cnf, args)
+	case "deactivate":
+		runDeactivate(cnf, args)
+	case "list-users":
+		runListUsers(cnf, args)
+	case "reset-password":
+		runResetPassword(cnf, args)
+	case "import-users":
+		runImportUsers(cnf, args)
+	case "normalize-emails":
+		runNormalizeEmails(cnf, args)
+	default:
+		log.Fatalf("Unknown command %q\n%s", command, usage)
 	}
 }
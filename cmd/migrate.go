@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"ryg-user-service/conf"
+	"ryg-user-service/db"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+const migrateUsage = "usage: migrate up | down [-force] [steps] | status"
+
+func runMigrate(cnf *conf.Config, args []string) {
+	if len(args) == 0 {
+		log.Fatal(migrateUsage)
+	}
+
+	db.ConnectDB(cnf.DB)
+	defer db.CloseDB()
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		if err := db.MigrateUp(ctx); err != nil {
+			log.Fatalf("Error migrating database: %v", err)
+		}
+	case "down":
+		fs := flag.NewFlagSet("migrate down", flag.ExitOnError)
+		force := fs.Bool("force", false, "allow rolling back the baseline migration, which drops all tables")
+		_ = fs.Parse(args[1:])
+
+		steps := 1
+		if fs.NArg() > 0 {
+			n, err := strconv.Atoi(fs.Arg(0))
+			if err != nil || n < 1 {
+				log.Fatalf("Invalid number of steps %q", fs.Arg(0))
+			}
+			steps = n
+		}
+		if err := db.MigrateDown(ctx, steps, *force); err != nil {
+			log.Fatalf("Error rolling back database: %v", err)
+		}
+	case "status":
+		statuses, err := db.MigrationStatuses(ctx)
+		if err != nil {
+			log.Fatalf("Error reading migration status: %v", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		w.Flush()
+	default:
+		log.Fatal(migrateUsage)
+	}
+}
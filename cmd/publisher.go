@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"ryg-user-service/conf"
+	pbe "ryg-user-service/gen_proto/email_service"
+	pbu "ryg-user-service/gen_proto/user_service"
+	"ryg-user-service/health"
+	"ryg-user-service/rabbit_mq"
+)
+
+// memoryPublisherLimit is the number of messages the memory backend keeps per
+// publisher.
+const memoryPublisherLimit = 1000
+
+type publishers struct {
+	email       rabbit_mq.Publisher[*pbe.GenericEmail]
+	userCreated rabbit_mq.Publisher[*pbu.User]
+	// close releases whatever the backend holds open.
+	close func()
+	// check reports whether the broker is reachable.
+	check health.Check
+	// reconnectRabbitMQ is set for the rabbitmq backend, to apply rotated credentials.
+	reconnectRabbitMQ func(cnf conf.RabbitMQConfig) error
+}
+
+// newPublishers builds the publishers for the configured backend.
+func newPublishers(cnf *conf.Config) publishers {
+	switch cnf.Publisher.Backend {
+	case conf.PublisherBackendRabbitMQ:
+		pm := rabbit_mq.NewPublisherManager(cnf.RabbitMQConfig)
+		return publishers{
+			email:             pm.GenericEmailQueuePublisher,
+			userCreated:       pm.UserCreatedPublisher,
+			close:             pm.Close,
+			check:             pm.Check,
+			reconnectRabbitMQ: pm.Reconnect,
+		}
+	case conf.PublisherBackendNats:
+		pm := rabbit_mq.NewNatsPublisherManager(cnf.NatsConfig)
+		return publishers{
+			email:       pm.GenericEmailPublisher,
+			userCreated: pm.UserCreatedPublisher,
+			close:       pm.Close,
+			check:       pm.Check,
+		}
+	case conf.PublisherBackendMemory:
+		return publishers{
+			email:       rabbit_mq.NewBoundedRecordingPublisher[*pbe.GenericEmail](memoryPublisherLimit),
+			userCreated: rabbit_mq.NewBoundedRecordingPublisher[*pbu.User](memoryPublisherLimit),
+			close:       func() {},
+			check:       alwaysHealthy,
+		}
+	case conf.PublisherBackendLog:
+		out, closeOut := openPublisherLog(cnf.Publisher.LogFile)
+		sink := rabbit_mq.NewLogSink(out)
+		return publishers{
+			email:       rabbit_mq.NewLogPublisher[*pbe.GenericEmail](sink),
+			userCreated: rabbit_mq.NewLogPublisher[*pbu.User](sink),
+			close:       closeOut,
+			check:       alwaysHealthy,
+		}
+	default:
+		log.Fatalf("Unknown publisher backend %q", cnf.Publisher.Backend)
+		return publishers{}
+	}
+}
+
+var errPublishingDisabled = errors.New("publishing is disabled")
+
+type disabledPublisher[T any] struct{}
+
+func (disabledPublisher[T]) Publish(context.Context, T) error {
+	return errPublishingDisabled
+}
+
+// disabledPublishers fail every publish without connecting to the broker.
+func disabledPublishers() publishers {
+	return publishers{
+		email:       disabledPublisher[*pbe.GenericEmail]{},
+		userCreated: disabledPublisher[*pbu.User]{},
+		close:       func() {},
+		check:       alwaysHealthy,
+	}
+}
+
+func alwaysHealthy(context.Context) error {
+	return nil
+}
+
+func openPublisherLog(path string) (io.Writer, func()) {
+	if path == "" {
+		return os.Stdout, func() {}
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		log.Fatalf("Failed to open publisher log file: %v", err)
+	}
+	return file, func() {
+		if err := file.Close(); err != nil {
+			slog.Warn("Failed to close publisher log file", "error", err)
+		}
+	}
+}
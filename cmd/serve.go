@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os/signal"
+	"ryg-user-service/conf"
+	"ryg-user-service/db"
+	"ryg-user-service/email_address"
+	"ryg-user-service/gen_proto/user_service"
+	"ryg-user-service/health"
+	"ryg-user-service/logging"
+	"ryg-user-service/metrics"
+	"ryg-user-service/password_hash"
+	"ryg-user-service/rabbit_mq"
+	"ryg-user-service/rate_limit"
+	"ryg-user-service/repository"
+	"ryg-user-service/retry_queue"
+	"ryg-user-service/service"
+	"ryg-user-service/tls_config"
+	"ryg-user-service/tracing"
+	"syscall"
+	"time"
+)
+
+const (
+	secretRotationTimeout = 10 * time.Second
+	tracingFlushTimeout   = 5 * time.Second
+)
+
+// watchSecrets applies rotated passwords from password files to the database pool
+// and the RabbitMQ connections, and renewed certificates to the gRPC listener.
+func watchSecrets(cnf *conf.Config, pubs publishers, cm *rabbit_mq.ConsumerManager, certs *tls_config.Reloader) []*conf.SecretWatcher {
+	var watchers []*conf.SecretWatcher
+
+	if cnf.DB.DBPasswordFile != "" {
+		watchers = append(watchers, conf.WatchSecretFile(cnf.DB.DBPasswordFile, cnf.DB.DBPassword, cnf.SecretRefreshInterval, func(password string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), secretRotationTimeout)
+			defer cancel()
+
+			if err := db.UpdatePassword(ctx, password); err != nil {
+				return fmt.Errorf("failed to rotate database password: %w", err)
+			}
+			return nil
+		}))
+	}
+
+	if cnf.RabbitMQConfig.PasswordFile != "" && pubs.reconnectRabbitMQ != nil {
+		rabbitMQConfig := cnf.RabbitMQConfig
+		watchers = append(watchers, conf.WatchSecretFile(rabbitMQConfig.PasswordFile, rabbitMQConfig.Password, cnf.SecretRefreshInterval, func(password string) error {
+			rabbitMQConfig.Password = password
+			err := pubs.reconnectRabbitMQ(rabbitMQConfig)
+			if cm != nil {
+				err = errors.Join(err, cm.Reconnect(rabbitMQConfig))
+			}
+			if err != nil {
+				return fmt.Errorf("failed to reconnect to RabbitMQ with rotated password: %w", err)
+			}
+			return nil
+		}))
+	}
+
+	if certs != nil {
+		for _, path := range certs.Files() {
+			current, err := conf.ReadSecretFile(path)
+			if err != nil {
+				log.Fatalf("Failed to read %s: %v", path, err)
+			}
+			watchers = append(watchers, conf.WatchSecretFile(path, current, cnf.SecretRefreshInterval, func(string) error {
+				if err := certs.Reload(); err != nil {
+					return fmt.Errorf("failed to reload TLS certificates: %w", err)
+				}
+				return nil
+			}))
+		}
+	}
+
+	return watchers
+}
+
+func runServe(cnf *conf.Config) {
+	shutdownTracing, err := tracing.Setup(context.Background(), cnf.Tracing)
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+
+	db.ConnectDB(cnf.DB)
+
+	if cnf.DB.AutoMigrate {
+		if err := db.MigrateUp(context.Background()); err != nil {
+			log.Fatalf("Error migrating database: %v", err)
+		}
+		slog.Info("Database migrated")
+	}
+
+	pubs := newPublishers(cnf)
+
+	serverCreds, loopbackCreds := insecure.NewCredentials(), insecure.NewCredentials()
+	var certs *tls_config.Reloader
+	if cnf.TLS.Enabled() {
+		certs, err = tls_config.NewReloader(cnf.TLS)
+		if err != nil {
+			log.Fatalf("Failed to load TLS certificates: %v", err)
+		}
+		serverCreds = credentials.NewTLS(certs.ServerConfig())
+		if cnf.GatewayAddr != "" {
+			loopbackConfig, err := certs.LoopbackClientConfig()
+			if err != nil {
+				log.Fatalf("Failed to configure the gateway: %v", err)
+			}
+			loopbackCreds = credentials.NewTLS(loopbackConfig)
+		}
+		slog.Info("Serving TLS", "mutual_tls", certs.MutualTLS())
+	}
+
+	users := repository.NewGormUserRepository(db.DB)
+	emailRetryQueue := retry_queue.NewEmailRetryQueue(db.DB, pubs.email, users.EmailSuppressed, cnf.EmailRetry)
+	emailRetryQueue.Start()
+
+	lis, err := net.Listen("tcp", cnf.RYGUserServiceUrl)
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+
+	interceptors := []grpc.UnaryServerInterceptor{
+		logging.UnaryServerInterceptor(),
+		metrics.UnaryServerInterceptor(),
+		service.AdminOnlyInterceptor(cnf.TLS.AdminPrincipals),
+	}
+	forwarder := rate_limit.NewTrustedForwarder()
+	var postgresLimiter *rate_limit.PostgresLimiter
+	switch cnf.RateLimit.Backend {
+	case conf.RateLimitBackendMemory:
+		limiter := rate_limit.NewMemoryLimiter(cnf.RateLimit.Rules)
+		interceptors = append(interceptors, rate_limit.UnaryServerInterceptor(limiter, cnf.RateLimit, forwarder))
+	case conf.RateLimitBackendPostgres:
+		postgresLimiter = rate_limit.NewPostgresLimiter(db.DB, cnf.RateLimit.Rules)
+		postgresLimiter.Start()
+		interceptors = append(interceptors, rate_limit.UnaryServerInterceptor(postgresLimiter, cnf.RateLimit, forwarder))
+	}
+
+	grpcServer := grpc.NewServer(
+		grpc.Creds(serverCreds),
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(interceptors...),
+	)
+
+	s := service.NewUserService(users, pubs.email, pubs.userCreated, emailRetryQueue, newPasswordPolicy(cnf), password_hash.New(cnf.PasswordHashing), email_address.NewNormalizer(cnf.EmailDomainRules))
+	user_service.RegisterUserServiceServer(grpcServer, s)
+
+	var cm *rabbit_mq.ConsumerManager
+	if cnf.Publisher.Backend == conf.PublisherBackendRabbitMQ {
+		cm = rabbit_mq.NewConsumerManager(cnf.RabbitMQConfig, rabbit_mq.EmailDeliveryQueueName)
+		rabbit_mq.HandleEmailDeliveryEvents(cm, s.MarkEmailUndeliverable)
+		cm.Start()
+	}
+
+	secretWatchers := watchSecrets(cnf, pubs, cm, certs)
+
+	checker := health.NewChecker(cnf.HealthCheckInterval, user_service.UserService_ServiceDesc.ServiceName)
+	checker.AddCheck("database", db.Ping)
+	checker.AddCheck("publisher", pubs.check)
+	if cm != nil {
+		checker.AddCheck("email delivery consumer", cm.Check)
+	}
+	checker.Register(grpcServer)
+	checker.Start()
+
+	var opsServer *http.Server
+	if cnf.OpsHTTPAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/", checker.HTTPHandler())
+		mux.Handle("GET /metrics", metrics.Handler())
+
+		opsServer = &http.Server{Addr: cnf.OpsHTTPAddr, Handler: mux}
+		go func() {
+			if err := opsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Failed to serve ops HTTP: %v", err)
+			}
+		}()
+	}
+
+	var gatewayServer *http.Server
+	var gatewayConn *grpc.ClientConn
+	if cnf.GatewayAddr != "" {
+		gatewayServer, gatewayConn = startGateway(cnf.GatewayAddr, lis.Addr(), loopbackCreds, certs, forwarder)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- grpcServer.Serve(lis)
+	}()
+
+	slog.Info("User Microservice is running", "address", cnf.RYGUserServiceUrl)
+	select {
+	case err := <-serveErr:
+		log.Fatalf("Failed to serve: %v", err)
+	case <-ctx.Done():
+		stop()
+	}
+
+	slog.Info("Shutting down")
+	shutdownStart := time.Now()
+
+	timed("Marked health as NOT_SERVING", checker.Shutdown)
+	if cnf.ShutdownDelay > 0 {
+		timed("Waited for load balancers to stop routing", func() {
+			time.Sleep(cnf.ShutdownDelay)
+		})
+	}
+	if gatewayServer != nil {
+		timed("Drained gateway requests", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), cnf.ShutdownTimeout)
+			defer cancel()
+
+			if err := gatewayServer.Shutdown(ctx); err != nil {
+				slog.Warn("Failed to drain gateway requests", "error", err)
+			}
+		})
+	}
+	timed("Drained in-flight RPCs", func() {
+		gracefulStop(grpcServer, cnf.ShutdownTimeout)
+	})
+	if gatewayConn != nil {
+		if err := gatewayConn.Close(); err != nil {
+			slog.Warn("Failed to close the gateway connection", "error", err)
+		}
+	}
+	if cm != nil {
+		timed("Stopped email delivery consumer", cm.Close)
+	}
+	timed("Stopped email retry queue", emailRetryQueue.Stop)
+	if postgresLimiter != nil {
+		postgresLimiter.Stop()
+	}
+	timed("Stopped health checks", checker.Stop)
+	for _, w := range secretWatchers {
+		w.Stop()
+	}
+	timed("Closed publishers", pubs.close)
+	if opsServer != nil {
+		timed("Closed ops HTTP server", func() {
+			if err := opsServer.Close(); err != nil {
+				slog.Warn("Failed to close ops HTTP server", "error", err)
+			}
+		})
+	}
+	timed("Closed database", db.CloseDB)
+	timed("Flushed traces", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingFlushTimeout)
+		defer cancel()
+
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Warn("Failed to flush traces", "error", err)
+		}
+	})
+
+	slog.Info("Shut down", "duration", time.Since(shutdownStart))
+}
+
+// gracefulStop waits for in-flight RPCs to finish and cancels the ones still
+// running after timeout.
+func gracefulStop(grpcServer *grpc.Server, timeout time.Duration) {
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(timeout):
+		slog.Warn("In-flight RPCs still running, cancelling them", "timeout", timeout)
+		grpcServer.Stop()
+		<-stopped
+	}
+}
+
+func timed(step string, fn func()) {
+	start := time.Now()
+	fn()
+	slog.Info(step, "duration", time.Since(start))
+}
@@ -1,49 +1,318 @@
 package conf
 
 import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
 	"os"
+	"time"
 )
 
+// ConfigFileEnv names the environment variable holding the path of an optional
+// YAML config file. Environment variables override values from the file.
+const ConfigFileEnv = "RYG_CONFIG_FILE"
+
 type DBConfig struct {
-	DBHost     string
-	DBPort     string
-	DBUser     string
-	DBPassword string
-	DBName     string
-	SSLMode    string
-	TimeZone   string
+	DBHost     string `yaml:"host" env:"POSTGRES_DB_HOST"`
+	DBPort     string `yaml:"port" env:"POSTGRES_DB_PORT"`
+	DBUser     string `yaml:"user" env:"POSTGRES_DB_USER"`
+	DBPassword string `yaml:"password" env:"POSTGRES_DB_PASSWORD"`
+	// DBPasswordFile takes precedence over DBPassword and is watched for rotation.
+	DBPasswordFile string `yaml:"password_file" env:"POSTGRES_DB_PASSWORD_FILE"`
+	DBName         string `yaml:"name" env:"POSTGRES_DB_NAME"`
+	SSLMode        string `yaml:"ssl_mode" env:"POSTGRES_DB_SSL_MODE"`
+	TimeZone       string `yaml:"timezone" env:"POSTGRES_DB_TIMEZONE"`
+	// AutoMigrate applies pending migrations when the server starts.
+	AutoMigrate bool `yaml:"auto_migrate" env:"POSTGRES_DB_AUTO_MIGRATE"`
 }
 
 type RabbitMQConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
+	Host     string `yaml:"host" env:"RABBITMQ_HOST"`
+	Port     string `yaml:"port" env:"RABBITMQ_PORT"`
+	User     string `yaml:"user" env:"RABBITMQ_USER"`
+	Password string `yaml:"password" env:"RABBITMQ_PASSWORD"`
+	// PasswordFile takes precedence over Password and is watched for rotation.
+	PasswordFile string `yaml:"password_file" env:"RABBITMQ_PASSWORD_FILE"`
+}
+
+type NatsConfig struct {
+	URL string `yaml:"url" env:"NATS_URL"`
+}
+
+const (
+	PublisherBackendRabbitMQ = "rabbitmq"
+	PublisherBackendNats     = "nats"
+	PublisherBackendMemory   = "memory"
+	PublisherBackendLog      = "log"
+)
+
+type PublisherConfig struct {
+	Backend string `yaml:"backend" env:"PUBLISHER_BACKEND"`
+	// LogFile is where the log backend writes messages; stdout when empty.
+	LogFile string `yaml:"log_file" env:"PUBLISHER_LOG_FILE"`
+}
+
+type EmailRetryConfig struct {
+	MaxAttempts    int           `yaml:"max_attempts" env:"EMAIL_RETRY_MAX_ATTEMPTS"`
+	InitialBackoff time.Duration `yaml:"initial_backoff" env:"EMAIL_RETRY_INITIAL_BACKOFF"`
+	MaxBackoff     time.Duration `yaml:"max_backoff" env:"EMAIL_RETRY_MAX_BACKOFF"`
+	PollInterval   time.Duration `yaml:"poll_interval" env:"EMAIL_RETRY_POLL_INTERVAL"`
+	BatchSize      int           `yaml:"batch_size" env:"EMAIL_RETRY_BATCH_SIZE"`
+}
+
+// TLSConfig enables TLS on the gRPC listener when CertFile and KeyFile are set.
+// The files are watched for renewal.
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file" env:"TLS_CERT_FILE"`
+	KeyFile  string `yaml:"key_file" env:"TLS_KEY_FILE"`
+	// ClientCAFile enables mutual TLS: clients must present a certificate signed
+	// by one of these CAs.
+	ClientCAFile string `yaml:"client_ca_file" env:"TLS_CLIENT_CA_FILE"`
+	// AdminPrincipals are the client identities, e.g. spiffe://cluster.local/ns/ops/sa/mailer-admin,
+	// allowed to call the dead letter RPCs. Nobody may call them when empty.
+	AdminPrincipals []string `yaml:"admin_principals"`
+}
+
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != ""
+}
+
+// EmailDomainRule normalizes the addresses of a mail provider that delivers
+// several spellings of an address to the same mailbox, e.g. Gmail.
+type EmailDomainRule struct {
+	// Domains are lowercase, e.g. gmail.com and googlemail.com.
+	Domains []string `yaml:"domains"`
+	// CanonicalDomain replaces every domain of the rule when set.
+	CanonicalDomain string `yaml:"canonical_domain"`
+	// IgnoreDots drops the dots of the local part.
+	IgnoreDots bool `yaml:"ignore_dots"`
+	// StripPlusTags drops everything from the first + of the local part.
+	StripPlusTags bool `yaml:"strip_plus_tags"`
+}
+
+const (
+	PasswordHashArgon2id = "argon2id"
+	PasswordHashBcrypt   = "bcrypt"
+
+	// The argon2id limits bound the work of verifying a stored hash, imported ones
+	// included, as well as the configured parameters.
+	MaxArgon2Memory      = 1024 * 1024 // KiB
+	MaxArgon2Iterations  = 64
+	MaxArgon2Parallelism = 64
+)
+
+// PasswordHashingConfig sets how new passwords are hashed. Hashes made with
+// another algorithm or other parameters still verify and are replaced on the
+// user's next successful login.
+type PasswordHashingConfig struct {
+	Algorithm  string `yaml:"algorithm" env:"PASSWORD_HASH_ALGORITHM"`
+	BcryptCost int    `yaml:"bcrypt_cost" env:"PASSWORD_HASH_BCRYPT_COST"`
+	// Argon2Memory is in KiB.
+	Argon2Memory      int `yaml:"argon2_memory" env:"PASSWORD_HASH_ARGON2_MEMORY"`
+	Argon2Iterations  int `yaml:"argon2_iterations" env:"PASSWORD_HASH_ARGON2_ITERATIONS"`
+	Argon2Parallelism int `yaml:"argon2_parallelism" env:"PASSWORD_HASH_ARGON2_PARALLELISM"`
+}
+
+type PasswordPolicyConfig struct {
+	MinLength int `yaml:"min_length" env:"PASSWORD_MIN_LENGTH"`
+	// MaxLength is in bytes, at most 72 with bcrypt, which ignores the rest.
+	MaxLength int `yaml:"max_length" env:"PASSWORD_MAX_LENGTH"`
+	// MinCharacterClasses is how many of lowercase letters, uppercase letters,
+	// digits and symbols a password must mix.
+	MinCharacterClasses int `yaml:"min_character_classes" env:"PASSWORD_MIN_CHARACTER_CLASSES"`
+	// DisallowPersonalInfo rejects passwords containing the user's email local
+	// part or a part of their name.
+	DisallowPersonalInfo bool `yaml:"disallow_personal_info" env:"PASSWORD_DISALLOW_PERSONAL_INFO"`
+	// BreachedListFile is a file or a directory of SHA-1 hashes of breached
+	// passwords, loaded at startup; see password_policy.LoadBreachedList.
+	BreachedListFile string `yaml:"breached_list_file" env:"PASSWORD_BREACHED_LIST_FILE"`
+}
+
+const (
+	RateLimitBackendNone     = "none"
+	RateLimitBackendMemory   = "memory"
+	RateLimitBackendPostgres = "postgres"
+
+	RateLimitKeyPeer      = "peer"
+	RateLimitKeyPrincipal = "principal"
+	RateLimitKeyAPIKey    = "api_key"
+)
+
+// RateLimitRule is a token bucket per client for one method: Burst requests at
+// once, refilled at Rate requests per second.
+type RateLimitRule struct {
+	// Method is a full gRPC method name, e.g. /auth_microservice.UserService/CreateUser,
+	// or * for every method without a rule of its own.
+	Method string `yaml:"method"`
+	// Key is what identifies a client: peer (IP address), principal (mutual TLS
+	// identity) or api_key (x-api-key metadata). Clients without a principal or
+	// a known API key are limited by their IP address.
+	Key   string  `yaml:"key"`
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+type RateLimitConfig struct {
+	// Backend is none, memory (per replica) or postgres (shared by all replicas).
+	Backend string          `yaml:"backend" env:"RATE_LIMIT_BACKEND"`
+	Rules   []RateLimitRule `yaml:"rules"`
+	// APIKeyDigests are the hex SHA-256 digests of the API keys that get buckets
+	// of their own; other keys could be made up for every request.
+	APIKeyDigests []string `yaml:"api_key_digests"`
+}
+
+const (
+	TracingExporterNone   = "none"
+	TracingExporterOTLP   = "otlp"
+	TracingExporterStdout = "stdout"
+)
+
+type TracingConfig struct {
+	Exporter string `yaml:"exporter" env:"TRACING_EXPORTER"`
+	// OTLPEndpoint is the host:port of the OTLP gRPC receiver.
+	OTLPEndpoint string `yaml:"otlp_endpoint" env:"TRACING_OTLP_ENDPOINT"`
+	OTLPInsecure bool   `yaml:"otlp_insecure" env:"TRACING_OTLP_INSECURE"`
+	// SampleRatio is the fraction of new traces that are sampled; traces started
+	// by a caller follow the caller's decision.
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO"`
 }
 
 type Config struct {
-	DB                DBConfig
-	RabbitMQConfig    RabbitMQConfig
-	RYGUserServiceUrl string
+	DB              DBConfig              `yaml:"db"`
+	RabbitMQConfig  RabbitMQConfig        `yaml:"rabbitmq"`
+	NatsConfig      NatsConfig            `yaml:"nats"`
+	Publisher       PublisherConfig       `yaml:"publisher"`
+	EmailRetry      EmailRetryConfig      `yaml:"email_retry"`
+	Tracing         TracingConfig         `yaml:"tracing"`
+	TLS             TLSConfig             `yaml:"tls"`
+	RateLimit       RateLimitConfig       `yaml:"rate_limit"`
+	PasswordPolicy  PasswordPolicyConfig  `yaml:"password_policy"`
+	PasswordHashing PasswordHashingConfig `yaml:"password_hashing"`
+	// EmailDomainRules apply on top of the normalization every email gets:
+	// surrounding spaces are trimmed and the domain is lowercased.
+	EmailDomainRules  []EmailDomainRule `yaml:"email_domain_rules"`
+	RYGUserServiceUrl string            `yaml:"listen_address" env:"RYG_USER_SERVICE_URL"`
+	// GatewayAddr is the address of the HTTP/JSON gateway to the gRPC API; the
+	// gateway is disabled when empty.
+	GatewayAddr string `yaml:"gateway_address" env:"GATEWAY_ADDRESS"`
+	// LogLevel is one of debug, info, warn and error; SQL statements are logged at debug.
+	LogLevel string `yaml:"log_level" env:"LOG_LEVEL"`
+	// OpsHTTPAddr is the address of the HTTP listener for /healthz, /readyz and
+	// /metrics; the listener is disabled when empty.
+	OpsHTTPAddr         string        `yaml:"ops_http_address" env:"OPS_HTTP_ADDRESS"`
+	HealthCheckInterval time.Duration `yaml:"health_check_interval" env:"HEALTH_CHECK_INTERVAL"`
+	// ShutdownDelay is how long the server keeps accepting requests after it
+	// reports NOT_SERVING, so that load balancers notice before it stops.
+	ShutdownDelay time.Duration `yaml:"shutdown_delay" env:"SHUTDOWN_DELAY"`
+	// ShutdownTimeout is how long in-flight RPCs may run after SIGINT or SIGTERM
+	// before they are cancelled.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	// SecretRefreshInterval is how often password and certificate files are
+	// checked for rotation.
+	SecretRefreshInterval time.Duration `yaml:"secret_refresh_interval" env:"SECRET_REFRESH_INTERVAL"`
 }
 
-func LoadConfig() *Config {
-	return &Config{
+// Default returns the configuration used for every value that is set neither in
+// the config file nor in the environment. config.example.yaml documents the same
+// values.
+func Default() Config {
+	return Config{
 		DB: DBConfig{
-			DBHost:     os.Getenv("POSTGRES_DB_HOST"),
-			DBPort:     os.Getenv("POSTGRES_DB_PORT"),
-			DBUser:     os.Getenv("POSTGRES_DB_USER"),
-			DBPassword: os.Getenv("POSTGRES_DB_PASSWORD"),
-			DBName:     os.Getenv("POSTGRES_DB_NAME"),
-			SSLMode:    os.Getenv("POSTGRES_DB_SSL_MODE"),
-			TimeZone:   os.Getenv("POSTGRES_DB_TIMEZONE"),
-		},
-		RYGUserServiceUrl: os.Getenv("RYG_USER_SERVICE_URL"),
+			DBPort:      "5432",
+			SSLMode:     "disable",
+			TimeZone:    "UTC",
+			AutoMigrate: true,
+		},
 		RabbitMQConfig: RabbitMQConfig{
-			Host:     os.Getenv("RABBITMQ_HOST"),
-			Port:     os.Getenv("RABBITMQ_PORT"),
-			User:     os.Getenv("RABBITMQ_USER"),
-			Password: os.Getenv("RABBITMQ_PASSWORD"),
+			Port: "5672",
+		},
+		NatsConfig: NatsConfig{
+			URL: "nats://127.0.0.1:4222",
+		},
+		Publisher: PublisherConfig{
+			Backend: PublisherBackendRabbitMQ,
+		},
+		EmailRetry: EmailRetryConfig{
+			MaxAttempts:    8,
+			InitialBackoff: 10 * time.Second,
+			MaxBackoff:     time.Hour,
+			PollInterval:   5 * time.Second,
+			BatchSize:      50,
+		},
+		Tracing: TracingConfig{
+			Exporter:     TracingExporterNone,
+			OTLPEndpoint: "localhost:4317",
+			OTLPInsecure: true,
+			SampleRatio:  1,
+		},
+		PasswordPolicy: PasswordPolicyConfig{
+			MinLength:            10,
+			MaxLength:            72,
+			MinCharacterClasses:  2,
+			DisallowPersonalInfo: true,
+		},
+		// bcrypt until the auth service verifies logins with VerifyCredentials
+		// instead of checking the hash from GetUserForLogin itself. The argon2id
+		// parameters are those recommended by OWASP.
+		PasswordHashing: PasswordHashingConfig{
+			Algorithm:         PasswordHashBcrypt,
+			BcryptCost:        10,
+			Argon2Memory:      19 * 1024,
+			Argon2Iterations:  2,
+			Argon2Parallelism: 1,
 		},
+		RateLimit: RateLimitConfig{
+			Backend: RateLimitBackendMemory,
+			Rules: []RateLimitRule{
+				{Method: "/auth_microservice.UserService/CreateUser", Key: RateLimitKeyPeer, Rate: 1, Burst: 10},
+				// Every call hashes a password, which takes 19 MiB with argon2id.
+				{Method: "/auth_microservice.UserService/VerifyCredentials", Key: RateLimitKeyPeer, Rate: 20, Burst: 40},
+			},
+		},
+		RYGUserServiceUrl:     ":50051",
+		LogLevel:              "info",
+		HealthCheckInterval:   10 * time.Second,
+		ShutdownDelay:         5 * time.Second,
+		ShutdownTimeout:       30 * time.Second,
+		SecretRefreshInterval: 30 * time.Second,
+	}
+}
+
+// LoadConfig applies the config file named by RYG_CONFIG_FILE, the environment
+// and the password files on top of the defaults, and validates the result. All
+// problems found are reported together.
+func LoadConfig() (*Config, error) {
+	cnf := Default()
+
+	if path := os.Getenv(ConfigFileEnv); path != "" {
+		if err := loadFile(path, &cnf); err != nil {
+			return nil, err
+		}
+	}
+
+	problems := &ValidationError{}
+	loadEnv(&cnf, problems)
+	loadSecretFiles(&cnf, problems)
+	cnf.validate(problems)
+
+	if err := problems.errOrNil(); err != nil {
+		return nil, err
+	}
+	return &cnf, nil
+}
+
+func loadFile(path string, cnf *Config) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cnf); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
 	}
+	return nil
 }
@@ -0,0 +1,78 @@
+package conf
+
+import (
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// loadEnv overrides every field tagged with `env` whose variable is set and not
+// empty. Values that can't be parsed are reported to problems.
+func loadEnv(cnf *Config, problems *ValidationError) {
+	loadEnvInto(reflect.ValueOf(cnf).Elem(), problems)
+}
+
+func loadEnvInto(v reflect.Value, problems *ValidationError) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+
+		if field.Type.Kind() == reflect.Struct && field.Type != durationType {
+			loadEnvInto(value, problems)
+			continue
+		}
+
+		key := field.Tag.Get("env")
+		if key == "" {
+			continue
+		}
+		raw, ok := os.LookupEnv(key)
+		if !ok || raw == "" {
+			continue
+		}
+
+		if err := setValue(value, raw); err != nil {
+			problems.add("%s: %v", key, err)
+		}
+	}
+}
+
+func setValue(value reflect.Value, raw string) error {
+	if value.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(d))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		value.SetFloat(f)
+	default:
+		panic("conf: unsupported field kind " + value.Kind().String())
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package conf
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReadSecretFile returns the content of a mounted secret without the trailing
+// newline most tools add.
+func ReadSecretFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+func loadSecretFiles(cnf *Config, problems *ValidationError) {
+	secrets := []struct {
+		name   string
+		path   string
+		target *string
+	}{
+		{"db.password_file", cnf.DB.DBPasswordFile, &cnf.DB.DBPassword},
+		{"rabbitmq.password_file", cnf.RabbitMQConfig.PasswordFile, &cnf.RabbitMQConfig.Password},
+	}
+
+	for _, secret := range secrets {
+		if secret.path == "" {
+			continue
+		}
+		value, err := ReadSecretFile(secret.path)
+		if err != nil {
+			problems.add("%s: %v", secret.name, err)
+			continue
+		}
+		*secret.target = value
+	}
+}
+
+// SecretWatcher polls a secret file and reports changes of its content. Polling
+// rather than inotify copes with the symlink swap Kubernetes uses to update
+// mounted secrets.
+type SecretWatcher struct {
+	path     string
+	current  string
+	onChange func(secret string) error
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// WatchSecretFile calls onChange with the new content every time the file at
+// path changes. current is the content the caller already uses. When onChange
+// fails, the change is applied again on the next poll.
+func WatchSecretFile(path, current string, interval time.Duration, onChange func(secret string) error) *SecretWatcher {
+	w := &SecretWatcher{
+		path:     path,
+		current:  current,
+		onChange: onChange,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go w.run(interval)
+	return w
+}
+
+func (w *SecretWatcher) run(interval time.Duration) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		}
+
+		secret, err := ReadSecretFile(w.path)
+		if err != nil {
+			slog.Error("Failed to read secret file", "path", w.path, "error", err)
+			continue
+		}
+		if secret == "" || secret == w.current {
+			continue
+		}
+
+		slog.Info("Secret file changed", "path", w.path)
+		if err := w.onChange(secret); err != nil {
+			slog.Error("Failed to apply secret file, retrying", "path", w.path, "delay", interval, "error", err)
+			continue
+		}
+		w.current = secret
+	}
+}
+
+func (w *SecretWatcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+		<-w.done
+	})
+}
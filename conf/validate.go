@@ -0,0 +1,253 @@
+package conf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ValidationError lists every problem found in a configuration.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	msg := "invalid configuration:"
+	for _, problem := range e.Problems {
+		msg += "\n  - " + problem
+	}
+	return msg
+}
+
+func (e *ValidationError) add(format string, args ...any) {
+	e.Problems = append(e.Problems, fmt.Sprintf(format, args...))
+}
+
+func (e *ValidationError) errOrNil() error {
+	if len(e.Problems) == 0 {
+		return nil
+	}
+	return e
+}
+
+const (
+	// bcryptMaxPasswordLength is the number of bytes bcrypt looks at.
+	bcryptMaxPasswordLength = 72
+	// maxPasswordLength bounds the work an argon2id hash of a request does.
+	maxPasswordLength = 1024
+)
+
+var sslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+var logLevels = []string{"debug", "info", "warn", "error"}
+
+var rateLimitBackends = []string{
+	RateLimitBackendNone,
+	RateLimitBackendMemory,
+	RateLimitBackendPostgres,
+}
+
+var rateLimitKeys = []string{
+	RateLimitKeyPeer,
+	RateLimitKeyPrincipal,
+	RateLimitKeyAPIKey,
+}
+
+var passwordHashAlgorithms = []string{
+	PasswordHashArgon2id,
+	PasswordHashBcrypt,
+}
+
+var tracingExporters = []string{
+	TracingExporterNone,
+	TracingExporterOTLP,
+	TracingExporterStdout,
+}
+
+var publisherBackends = []string{
+	PublisherBackendRabbitMQ,
+	PublisherBackendNats,
+	PublisherBackendMemory,
+	PublisherBackendLog,
+}
+
+func (c *Config) validate(problems *ValidationError) {
+	requireValue(problems, "db.host", c.DB.DBHost)
+	requireValue(problems, "db.user", c.DB.DBUser)
+	requireValue(problems, "db.name", c.DB.DBName)
+	validatePort(problems, "db.port", c.DB.DBPort)
+	validateOneOf(problems, "db.ssl_mode", c.DB.SSLMode, sslModes)
+
+	if _, _, err := net.SplitHostPort(c.RYGUserServiceUrl); err != nil {
+		problems.add("listen_address: %q is not a host:port address", c.RYGUserServiceUrl)
+	}
+
+	validateOneOf(problems, "log_level", c.LogLevel, logLevels)
+
+	validateOneOf(problems, "publisher.backend", c.Publisher.Backend, publisherBackends)
+	switch c.Publisher.Backend {
+	case PublisherBackendRabbitMQ:
+		requireValue(problems, "rabbitmq.host", c.RabbitMQConfig.Host)
+		requireValue(problems, "rabbitmq.user", c.RabbitMQConfig.User)
+		validatePort(problems, "rabbitmq.port", c.RabbitMQConfig.Port)
+	case PublisherBackendNats:
+		requireValue(problems, "nats.url", c.NatsConfig.URL)
+	}
+
+	if c.EmailRetry.MaxAttempts < 1 {
+		problems.add("email_retry.max_attempts: must be at least 1")
+	}
+	if c.EmailRetry.BatchSize < 1 {
+		problems.add("email_retry.batch_size: must be at least 1")
+	}
+	if c.EmailRetry.InitialBackoff <= 0 || c.EmailRetry.PollInterval <= 0 {
+		problems.add("email_retry: initial_backoff and poll_interval must be positive")
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		problems.add("tls: cert_file and key_file must be set together")
+	}
+	if c.TLS.ClientCAFile != "" && !c.TLS.Enabled() {
+		problems.add("tls.client_ca_file: requires cert_file and key_file")
+	}
+	if len(c.TLS.AdminPrincipals) > 0 && c.TLS.ClientCAFile == "" {
+		problems.add("tls.admin_principals: requires client_ca_file")
+	}
+
+	hashing := c.PasswordHashing
+	validateOneOf(problems, "password_hashing.algorithm", hashing.Algorithm, passwordHashAlgorithms)
+	if hashing.BcryptCost < bcrypt.MinCost || hashing.BcryptCost > bcrypt.MaxCost {
+		problems.add("password_hashing.bcrypt_cost: must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	if hashing.Argon2Memory < 8*hashing.Argon2Parallelism || hashing.Argon2Memory > MaxArgon2Memory {
+		problems.add("password_hashing.argon2_memory: must be at least 8 KiB per thread and at most %d KiB", MaxArgon2Memory)
+	}
+	if hashing.Argon2Iterations < 1 || hashing.Argon2Iterations > MaxArgon2Iterations {
+		problems.add("password_hashing.argon2_iterations: must be between 1 and %d", MaxArgon2Iterations)
+	}
+	if hashing.Argon2Parallelism < 1 || hashing.Argon2Parallelism > MaxArgon2Parallelism {
+		problems.add("password_hashing.argon2_parallelism: must be between 1 and %d", MaxArgon2Parallelism)
+	}
+
+	domains := map[string]bool{}
+	for i, rule := range c.EmailDomainRules {
+		name := fmt.Sprintf("email_domain_rules[%d]", i)
+		if len(rule.Domains) == 0 {
+			problems.add("%s.domains: at least one domain is required", name)
+		}
+		for _, domain := range rule.Domains {
+			if domain == "" || domain != strings.ToLower(domain) || strings.Contains(domain, "@") {
+				problems.add("%s.domains: %q is not a lowercase domain", name, domain)
+			}
+			if domains[domain] {
+				problems.add("%s.domains: %s has more than one rule", name, domain)
+			}
+			domains[domain] = true
+		}
+		if rule.CanonicalDomain != strings.ToLower(rule.CanonicalDomain) || strings.Contains(rule.CanonicalDomain, "@") {
+			problems.add("%s.canonical_domain: %q is not a lowercase domain", name, rule.CanonicalDomain)
+		}
+	}
+
+	policy := c.PasswordPolicy
+	if policy.MinLength < 1 {
+		problems.add("password_policy.min_length: must be at least 1")
+	}
+	maxLength := maxPasswordLength
+	if hashing.Algorithm == PasswordHashBcrypt {
+		maxLength = bcryptMaxPasswordLength
+	}
+	if policy.MaxLength < policy.MinLength || policy.MaxLength > maxLength {
+		problems.add("password_policy.max_length: must be between min_length and %d with %s hashing", maxLength, hashing.Algorithm)
+	}
+	if policy.MinCharacterClasses < 0 || policy.MinCharacterClasses > 4 {
+		problems.add("password_policy.min_character_classes: must be between 0 and 4")
+	}
+
+	validateOneOf(problems, "rate_limit.backend", c.RateLimit.Backend, rateLimitBackends)
+	methods := map[string]bool{}
+	for i, rule := range c.RateLimit.Rules {
+		name := fmt.Sprintf("rate_limit.rules[%d]", i)
+		if rule.Method != "*" && !strings.HasPrefix(rule.Method, "/") {
+			problems.add("%s.method: %q is neither * nor a full method name", name, rule.Method)
+		}
+		if methods[rule.Method] {
+			problems.add("%s.method: %s has more than one rule", name, rule.Method)
+		}
+		methods[rule.Method] = true
+		validateOneOf(problems, name+".key", rule.Key, rateLimitKeys)
+		if rule.Key == RateLimitKeyAPIKey && len(c.RateLimit.APIKeyDigests) == 0 {
+			problems.add("%s.key: api_key requires rate_limit.api_key_digests", name)
+		}
+		if rule.Rate <= 0 || rule.Burst < 1 {
+			problems.add("%s: rate must be positive and burst at least 1", name)
+		}
+	}
+
+	for i, digest := range c.RateLimit.APIKeyDigests {
+		if b, err := hex.DecodeString(digest); err != nil || len(b) != sha256.Size {
+			problems.add("rate_limit.api_key_digests[%d]: must be a hex SHA-256 digest", i)
+		}
+	}
+
+	validateOneOf(problems, "tracing.exporter", c.Tracing.Exporter, tracingExporters)
+	if c.Tracing.Exporter == TracingExporterOTLP {
+		if _, _, err := net.SplitHostPort(c.Tracing.OTLPEndpoint); err != nil {
+			problems.add("tracing.otlp_endpoint: %q is not a host:port address", c.Tracing.OTLPEndpoint)
+		}
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		problems.add("tracing.sample_ratio: must be between 0 and 1")
+	}
+
+	if c.GatewayAddr != "" {
+		if _, _, err := net.SplitHostPort(c.GatewayAddr); err != nil {
+			problems.add("gateway_address: %q is not a host:port address", c.GatewayAddr)
+		}
+	}
+	if c.OpsHTTPAddr != "" {
+		if _, _, err := net.SplitHostPort(c.OpsHTTPAddr); err != nil {
+			problems.add("ops_http_address: %q is not a host:port address", c.OpsHTTPAddr)
+		}
+	}
+	if c.HealthCheckInterval <= 0 {
+		problems.add("health_check_interval: must be positive")
+	}
+	if c.ShutdownDelay < 0 {
+		problems.add("shutdown_delay: must not be negative")
+	}
+	if c.ShutdownTimeout <= 0 {
+		problems.add("shutdown_timeout: must be positive")
+	}
+	if c.SecretRefreshInterval <= 0 {
+		problems.add("secret_refresh_interval: must be positive")
+	}
+	if c.EmailRetry.MaxBackoff < c.EmailRetry.InitialBackoff {
+		problems.add("email_retry.max_backoff: must not be shorter than initial_backoff")
+	}
+}
+
+func requireValue(problems *ValidationError, name, value string) {
+	if value == "" {
+		problems.add("%s: required", name)
+	}
+}
+
+func validatePort(problems *ValidationError, name, value string) {
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		problems.add("%s: %q is not a valid port", name, value)
+	}
+}
+
+func validateOneOf(problems *ValidationError, name, value string, allowed []string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	problems.add("%s: %q is not one of %v", name, value, allowed)
+}
@@ -0,0 +1,35 @@
+package conf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidatePasswordMaxLength(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		maxLength int
+		valid     bool
+	}{
+		{PasswordHashBcrypt, bcryptMaxPasswordLength, true},
+		{PasswordHashBcrypt, bcryptMaxPasswordLength + 1, false},
+		{PasswordHashArgon2id, bcryptMaxPasswordLength + 1, true},
+		{PasswordHashArgon2id, maxPasswordLength, true},
+		{PasswordHashArgon2id, maxPasswordLength + 1, false},
+	}
+	for _, tc := range tests {
+		cnf := Default()
+		cnf.PasswordHashing.Algorithm = tc.algorithm
+		cnf.PasswordPolicy.MaxLength = tc.maxLength
+
+		problems := &ValidationError{}
+		cnf.validate(problems)
+		invalid := false
+		for _, problem := range problems.Problems {
+			invalid = invalid || strings.HasPrefix(problem, "password_policy.max_length")
+		}
+		if invalid == tc.valid {
+			t.Errorf("max_length %d with %s: got problems %q, want valid %t", tc.maxLength, tc.algorithm, problems.Problems, tc.valid)
+		}
+	}
+}
@@ -1,43 +1,75 @@
 package db
 
 import (
+	"context"
 	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 	"log"
+	"log/slog"
 	"ryg-user-service/conf"
-	"ryg-user-service/model"
+	"ryg-user-service/logging"
+	"ryg-user-service/metrics"
+	"ryg-user-service/tracing"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	maxIdleConns = 2
+	// connMaxLifetime bounds how long connections opened with a rotated-out
+	// password stay in the pool.
+	connMaxLifetime = 30 * time.Minute
 )
 
 var DB *gorm.DB
 
+// password is read every time a new connection is opened, so that a rotated
+// password applies without reopening the pool.
+var password atomic.Value
+
 func ConnectDB(cnf conf.DBConfig) {
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=%s",
+	dsn := fmt.Sprintf("host=%s user=%s dbname=%s port=%s sslmode=%s TimeZone=%s",
 		cnf.DBHost,
 		cnf.DBUser,
-		cnf.DBPassword,
 		cnf.DBName,
 		cnf.DBPort,
 		cnf.SSLMode,
 		cnf.TimeZone,
 	)
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+	connConfig, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		log.Fatalf("Error parsing the database config: %v", err)
+	}
+
+	password.Store(cnf.DBPassword)
+	sqlDB := stdlib.OpenDB(*connConfig, stdlib.OptionBeforeConnect(func(_ context.Context, cc *pgx.ConnConfig) error {
+		cc.Password = password.Load().(string)
+		return nil
+	}))
+	sqlDB.SetMaxIdleConns(maxIdleConns)
+	sqlDB.SetConnMaxLifetime(connMaxLifetime)
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: logging.GormLogger{},
 	})
 
 	if err != nil {
 		log.Fatalf("Error connecting to the database: %v", err)
 	}
+	if err := db.Use(metrics.GormPlugin{}); err != nil {
+		log.Fatalf("Error registering the metrics plugin: %v", err)
+	}
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		log.Fatalf("Error registering the tracing plugin: %v", err)
+	}
+	metrics.RegisterDBStats(sqlDB)
 
 	DB = db
-	fmt.Println("Connected to the database")
-
-	if err := DB.AutoMigrate(&model.User{}); err != nil {
-		log.Fatalf("Error migrating database: %v", err)
-	}
-	fmt.Println("Database migrated")
+	slog.Info("Connected to the database")
 }
 
 func CloseDB() {
@@ -48,5 +80,37 @@ func CloseDB() {
 	if err := sqlDB.Close(); err != nil {
 		log.Fatalf("Error closing the database: %v", err)
 	}
-	fmt.Println("Database connection closed")
+	slog.Info("Database connection closed")
+}
+
+func Ping(ctx context.Context) error {
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// UpdatePassword switches new connections to a rotated password. It checks the
+// password on a fresh connection first and then drops idle connections, leaving
+// the ones in use to finish their work.
+func UpdatePassword(ctx context.Context, newPassword string) error {
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+
+	oldPassword := password.Load()
+	password.Store(newPassword)
+
+	sqlDB.SetMaxIdleConns(0)
+	defer sqlDB.SetMaxIdleConns(maxIdleConns)
+
+	if err := sqlDB.PingContext(ctx); err != nil {
+		password.Store(oldPassword)
+		return fmt.Errorf("rotated database password was rejected: %w", err)
+	}
+
+	slog.Info("Database password rotated")
+	return nil
 }
@@ -0,0 +1,4 @@
+-- Drops all data; migrate down refuses to run this without -force.
+DROP TABLE IF EXISTS dead_letter_emails;
+DROP TABLE IF EXISTS pending_emails;
+DROP TABLE IF EXISTS users;
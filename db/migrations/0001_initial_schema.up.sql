@@ -0,0 +1,43 @@
+-- Baseline of the schema previously created by gorm AutoMigrate. Every statement is
+-- idempotent so that databases created by AutoMigrate are adopted as they are.
+
+CREATE TABLE IF NOT EXISTS users (
+    id        bigserial PRIMARY KEY,
+    full_name text,
+    password  text,
+    email     text CONSTRAINT uni_users_email UNIQUE,
+    role      varchar(10) CONSTRAINT chk_users_role CHECK (role IN ('admin', 'user', 'pro_user')),
+    is_active boolean DEFAULT true
+);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locale varchar(35) NOT NULL DEFAULT 'en';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone varchar(64) NOT NULL DEFAULT 'UTC';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_status varchar(20) NOT NULL DEFAULT 'deliverable';
+ALTER TABLE users DROP CONSTRAINT IF EXISTS chk_users_email_status;
+ALTER TABLE users ADD CONSTRAINT chk_users_email_status CHECK (email_status IN ('deliverable', 'bounced', 'complained'));
+
+CREATE TABLE IF NOT EXISTS pending_emails (
+    id              bigserial PRIMARY KEY,
+    message_id      varchar(36) NOT NULL,
+    recipient       text        NOT NULL,
+    subject         text,
+    body            text,
+    attempts        bigint      NOT NULL DEFAULT 0,
+    last_error      text,
+    next_attempt_at timestamptz NOT NULL,
+    created_at      timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_pending_emails_next_attempt_at ON pending_emails (next_attempt_at);
+
+CREATE TABLE IF NOT EXISTS dead_letter_emails (
+    id         bigserial PRIMARY KEY,
+    message_id varchar(36) NOT NULL,
+    recipient  text        NOT NULL,
+    subject    text,
+    body       text,
+    attempts   bigint      NOT NULL,
+    last_error text,
+    created_at timestamptz,
+    failed_at  timestamptz NOT NULL
+);
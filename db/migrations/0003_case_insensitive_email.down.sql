@@ -0,0 +1,3 @@
+-- Normalized emails are kept; they are unique as they are.
+DROP INDEX IF EXISTS uni_users_email_lower;
+ALTER TABLE users ADD CONSTRAINT uni_users_email UNIQUE (email);
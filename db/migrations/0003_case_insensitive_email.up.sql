@@ -0,0 +1,35 @@
+-- Emails are unique whatever their case and are stored trimmed with a lowercase
+-- domain, as the service normalizes them. Accounts whose emails differ only in
+-- case or surrounding spaces can't be merged automatically, so their ids are
+-- reported and the migration fails until an operator resolves them.
+DO $$
+DECLARE
+    collisions text;
+BEGIN
+    SELECT string_agg(ids, '; ')
+      INTO collisions
+      FROM (
+          SELECT string_agg(id::text, ', ' ORDER BY id) AS ids
+            FROM users
+           WHERE email IS NOT NULL
+           GROUP BY lower(btrim(email))
+          HAVING count(*) > 1
+      ) AS duplicates;
+
+    IF collisions IS NOT NULL THEN
+        RAISE EXCEPTION 'users with emails that differ only in case or surrounding spaces, by id: %', collisions
+            USING HINT = 'Delete or change the email of all but one user of each group, then migrate again.';
+    END IF;
+END
+$$;
+
+UPDATE users
+   SET email = CASE
+           WHEN position('@' IN btrim(email)) > 1
+               THEN substring(btrim(email) FROM '^(.*@)') || lower(substring(btrim(email) FROM '[^@]*$'))
+           ELSE btrim(email)
+       END
+ WHERE email IS NOT NULL;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS uni_users_email;
+CREATE UNIQUE INDEX IF NOT EXISTS uni_users_email_lower ON users (lower(email));
@@ -0,0 +1,2 @@
+ALTER TABLE dead_letter_emails DROP COLUMN IF EXISTS critical;
+ALTER TABLE pending_emails DROP COLUMN IF EXISTS critical;
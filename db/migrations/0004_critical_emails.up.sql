@@ -0,0 +1,3 @@
+-- Critical emails are sent even to addresses that bounced or complained.
+ALTER TABLE pending_emails ADD COLUMN IF NOT EXISTS critical boolean NOT NULL DEFAULT false;
+ALTER TABLE dead_letter_emails ADD COLUMN IF NOT EXISTS critical boolean NOT NULL DEFAULT false;
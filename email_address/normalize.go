@@ -0,0 +1,48 @@
+package email_address
+
+import (
+	"ryg-user-service/conf"
+	"strings"
+)
+
+// Normalizer turns the spellings of an address that reach the same mailbox into
+// one, so that they can't be registered as separate accounts.
+type Normalizer struct {
+	rules map[string]conf.EmailDomainRule
+}
+
+func NewNormalizer(rules []conf.EmailDomainRule) *Normalizer {
+	n := &Normalizer{rules: map[string]conf.EmailDomainRule{}}
+	for _, rule := range rules {
+		for _, domain := range rule.Domains {
+			n.rules[domain] = rule
+		}
+	}
+	return n
+}
+
+// Normalize trims email, lowercases its domain and applies the rule of the
+// domain, if any. The local part keeps its case, since uniqueness ignores case
+// anyway. Strings without a local part and a domain are only trimmed, for
+// validation to reject.
+func (n *Normalizer) Normalize(email string) string {
+	email = strings.TrimSpace(email)
+	at := strings.LastIndex(email, "@")
+	if at < 1 || at == len(email)-1 {
+		return email
+	}
+
+	local, domain := email[:at], strings.ToLower(email[at+1:])
+	if rule, ok := n.rules[domain]; ok {
+		if i := strings.IndexByte(local, '+'); rule.StripPlusTags && i > 0 {
+			local = local[:i]
+		}
+		if rule.IgnoreDots {
+			local = strings.ReplaceAll(local, ".", "")
+		}
+		if rule.CanonicalDomain != "" {
+			domain = rule.CanonicalDomain
+		}
+	}
+	return local + "@" + domain
+}
@@ -0,0 +1,248 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	pbu "ryg-user-service/gen_proto/user_service"
+	"ryg-user-service/logging"
+	"ryg-user-service/rate_limit"
+	"strconv"
+	"strings"
+)
+
+const maxBodySize = 1 << 20
+
+// forwardedHeaders are passed on to the gRPC server as metadata.
+var forwardedHeaders = []string{"authorization", logging.RequestIDHeader, "traceparent", "tracestate", "x-api-key"}
+
+var (
+	marshalOptions   = protojson.MarshalOptions{EmitUnpopulated: true}
+	unmarshalOptions = protojson.UnmarshalOptions{}
+)
+
+// route maps an HTTP method and path onto an RPC. Path parameters, e.g. {id},
+// and for routes without a body the query parameters, are set on the request
+// fields of the same name.
+type route struct {
+	method string
+	path   string
+	rpc    protoreflect.MethodDescriptor
+	body   bool
+	// status is sent on success; responses without content always get 204.
+	status     int
+	newRequest func() proto.Message
+	call       func(ctx context.Context, req proto.Message, header *metadata.MD) (proto.Message, error)
+	forwarder  *rate_limit.TrustedForwarder
+}
+
+func newRoute[Req, Resp proto.Message](method, path, rpc string, body bool, successStatus int, call func(context.Context, Req, ...grpc.CallOption) (Resp, error)) route {
+	md := pbu.File_user_proto.Services().ByName("UserService").Methods().ByName(protoreflect.Name(rpc))
+	if md == nil {
+		panic("gateway: unknown rpc " + rpc)
+	}
+
+	return route{
+		method: method,
+		path:   path,
+		rpc:    md,
+		body:   body,
+		status: successStatus,
+		newRequest: func() proto.Message {
+			var req Req
+			return req.ProtoReflect().New().Interface()
+		},
+		call: func(ctx context.Context, req proto.Message, header *metadata.MD) (proto.Message, error) {
+			return call(ctx, req.(Req), grpc.Header(header))
+		},
+	}
+}
+
+// routes lists the RPCs served over HTTP. GetUserForLogin and VerifyCredentials
+// are for the auth service and the dead letter RPCs are for operators, so none
+// of them is exposed.
+func routes(client pbu.UserServiceClient) []route {
+	return []route{
+		newRoute("POST", "/v1/users", "CreateUser", true, http.StatusCreated, client.CreateUser),
+		newRoute("GET", "/v1/users/{id}", "GetUserById", false, http.StatusOK, client.GetUserById),
+		newRoute("PUT", "/v1/users/{id}", "UpdateUser", true, http.StatusOK, client.UpdateUser),
+		newRoute("DELETE", "/v1/users/{id}", "DeleteUser", false, http.StatusOK, client.DeleteUser),
+	}
+}
+
+// NewHandler serves the user service as JSON over HTTP by calling client, and
+// its OpenAPI document at /openapi.json. forwarder tells the server the addresses
+// of the HTTP clients.
+func NewHandler(client pbu.UserServiceClient, forwarder *rate_limit.TrustedForwarder) http.Handler {
+	mux := http.NewServeMux()
+
+	rs := routes(client)
+	for _, r := range rs {
+		r.forwarder = forwarder
+		mux.Handle(r.method+" "+r.path, r)
+	}
+
+	openAPI := openAPIDocument(rs)
+	mux.HandleFunc("GET /openapi.json", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(openAPI)
+	})
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, status.Errorf(codes.NotFound, "no route for %s %s", r.Method, r.URL.Path))
+	})
+	return mux
+}
+
+func (rt route) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := rt.newRequest()
+	if err := rt.bind(r, req); err != nil {
+		writeError(w, status.Error(codes.InvalidArgument, err.Error()))
+		return
+	}
+
+	var header metadata.MD
+	resp, err := rt.call(outgoingContext(r, rt.forwarder), req, &header)
+	if values := header.Get(logging.RequestIDHeader); len(values) > 0 {
+		w.Header().Set(logging.RequestIDHeader, values[0])
+	}
+	if values := header.Get(rate_limit.RetryAfterHeader); len(values) > 0 {
+		w.Header().Set("Retry-After", values[0])
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if _, empty := resp.(*emptypb.Empty); empty {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	body, err := marshalOptions.Marshal(resp)
+	if err != nil {
+		writeError(w, status.Errorf(codes.Internal, "failed to encode response: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(rt.status)
+	_, _ = w.Write(body)
+}
+
+func (rt route) bind(r *http.Request, req proto.Message) error {
+	if rt.body {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+		if err != nil {
+			return fmt.Errorf("failed to read body: %w", err)
+		}
+		if len(body) > 0 {
+			if err := unmarshalOptions.Unmarshal(body, req); err != nil {
+				return fmt.Errorf("invalid body: %w", err)
+			}
+		}
+	} else {
+		for name, values := range r.URL.Query() {
+			if err := setField(req, name, values[len(values)-1]); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, name := range pathParams(rt.path) {
+		if err := setField(req, name, r.PathValue(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setField sets the scalar field called name, by its proto or its JSON name.
+func setField(msg proto.Message, name, raw string) error {
+	fields := msg.ProtoReflect().Descriptor().Fields()
+	fd := fields.ByName(protoreflect.Name(name))
+	if fd == nil {
+		fd = fields.ByJSONName(name)
+	}
+	if fd == nil || fd.IsList() || fd.IsMap() {
+		return fmt.Errorf("unknown parameter %q", name)
+	}
+
+	var value protoreflect.Value
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		value = protoreflect.ValueOfString(raw)
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a boolean", name, raw)
+		}
+		value = protoreflect.ValueOfBool(b)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not an integer", name, raw)
+		}
+		value = protoreflect.ValueOfInt32(int32(n))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not an integer", name, raw)
+		}
+		value = protoreflect.ValueOfInt64(n)
+	default:
+		return fmt.Errorf("parameter %q can't be set from a string", name)
+	}
+
+	msg.ProtoReflect().Set(fd, value)
+	return nil
+}
+
+func pathParams(path string) []string {
+	var params []string
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			params = append(params, strings.TrimSuffix(segment[1:len(segment)-1], "..."))
+		}
+	}
+	return params
+}
+
+func outgoingContext(r *http.Request, forwarder *rate_limit.TrustedForwarder) context.Context {
+	md := metadata.MD{}
+	for _, header := range forwardedHeaders {
+		if value := r.Header.Get(header); value != "" {
+			md.Set(header, value)
+		}
+	}
+	// The gRPC server sees the gateway as its peer; this tells it the client's
+	// address. Headers the client sent itself are not trusted.
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		forwarder.Forward(md, host)
+	}
+	return metadata.NewOutgoingContext(r.Context(), md)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	body, marshalErr := marshalOptions.Marshal(st.Proto())
+	if marshalErr != nil {
+		slog.Error("Failed to encode error response", "error", marshalErr)
+		http.Error(w, st.Message(), HTTPStatus(st.Code()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(HTTPStatus(st.Code()))
+	_, _ = w.Write(body)
+}
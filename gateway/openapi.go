@@ -0,0 +1,185 @@
+package gateway
+
+import (
+	"encoding/json"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const statusSchema = "google.rpc.Status"
+
+// openAPIDocument describes the routes in OpenAPI 3.0, with the schemas derived
+// from the request and response message descriptors.
+func openAPIDocument(rs []route) []byte {
+	schemas := map[string]any{
+		statusSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"code":    map[string]any{"type": "integer", "format": "int32"},
+				"message": map[string]any{"type": "string"},
+				"details": map[string]any{"type": "array", "items": map[string]any{"type": "object"}},
+			},
+		},
+	}
+
+	paths := map[string]any{}
+	for _, r := range rs {
+		operation := map[string]any{
+			"operationId": string(r.rpc.Name()),
+			"responses":   responses(r, schemas),
+		}
+
+		var params []any
+		pathParamNames := map[string]bool{}
+		for _, name := range pathParams(r.path) {
+			pathParamNames[name] = true
+			params = append(params, parameter(r.rpc.Input().Fields().ByName(protoreflect.Name(name)), "path", true))
+		}
+		if r.body {
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content":  jsonContent(messageSchema(r.rpc.Input(), schemas)),
+			}
+		} else {
+			fields := r.rpc.Input().Fields()
+			for i := 0; i < fields.Len(); i++ {
+				fd := fields.Get(i)
+				if !pathParamNames[string(fd.Name())] && !fd.IsList() && !fd.IsMap() && fd.Message() == nil {
+					params = append(params, parameter(fd, "query", false))
+				}
+			}
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+
+		item, ok := paths[r.path].(map[string]any)
+		if !ok {
+			item = map[string]any{}
+			paths[r.path] = item
+		}
+		item[strings.ToLower(r.method)] = operation
+	}
+
+	doc := map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "RYG User Service",
+			"version": "v1",
+		},
+		"paths":      paths,
+		"components": map[string]any{"schemas": schemas},
+	}
+
+	body, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		panic("gateway: failed to encode the OpenAPI document: " + err.Error())
+	}
+	return body
+}
+
+func responses(r route, schemas map[string]any) map[string]any {
+	resp := map[string]any{
+		"default": map[string]any{
+			"description": "Error",
+			"content":     jsonContent(ref(statusSchema)),
+		},
+	}
+
+	if r.rpc.Output().FullName() == "google.protobuf.Empty" {
+		resp["204"] = map[string]any{"description": "No content"}
+		return resp
+	}
+	resp[strconv.Itoa(r.status)] = map[string]any{
+		"description": http.StatusText(r.status),
+		"content":     jsonContent(messageSchema(r.rpc.Output(), schemas)),
+	}
+	return resp
+}
+
+func parameter(fd protoreflect.FieldDescriptor, in string, required bool) map[string]any {
+	return map[string]any{
+		"name":     fd.JSONName(),
+		"in":       in,
+		"required": required,
+		"schema":   fieldSchema(fd, nil),
+	}
+}
+
+// messageSchema adds md and the messages it references to schemas and returns a
+// reference to it.
+func messageSchema(md protoreflect.MessageDescriptor, schemas map[string]any) map[string]any {
+	name := string(md.FullName())
+	if _, ok := schemas[name]; ok {
+		return ref(name)
+	}
+
+	properties := map[string]any{}
+	schemas[name] = map[string]any{"type": "object", "properties": properties}
+
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		properties[fd.JSONName()] = fieldSchema(fd, schemas)
+	}
+	return ref(name)
+}
+
+func fieldSchema(fd protoreflect.FieldDescriptor, schemas map[string]any) map[string]any {
+	if fd.IsMap() {
+		return map[string]any{"type": "object", "additionalProperties": singularSchema(fd.MapValue(), schemas)}
+	}
+	if fd.IsList() {
+		return map[string]any{"type": "array", "items": singularSchema(fd, schemas)}
+	}
+	return singularSchema(fd, schemas)
+}
+
+// singularSchema follows the protojson encoding, e.g. 64-bit integers are strings.
+func singularSchema(fd protoreflect.FieldDescriptor, schemas map[string]any) map[string]any {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return map[string]any{"type": "string"}
+	case protoreflect.BytesKind:
+		return map[string]any{"type": "string", "format": "byte"}
+	case protoreflect.BoolKind:
+		return map[string]any{"type": "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return map[string]any{"type": "integer", "format": "int64", "minimum": 0}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return map[string]any{"type": "string", "format": "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return map[string]any{"type": "string", "format": "uint64"}
+	case protoreflect.FloatKind:
+		return map[string]any{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return map[string]any{"type": "number", "format": "double"}
+	case protoreflect.EnumKind:
+		values := fd.Enum().Values()
+		names := make([]string, values.Len())
+		for i := range names {
+			names[i] = string(values.Get(i).Name())
+		}
+		return map[string]any{"type": "string", "enum": names}
+	}
+
+	switch fd.Message().FullName() {
+	case "google.protobuf.Timestamp":
+		return map[string]any{"type": "string", "format": "date-time"}
+	case "google.protobuf.Duration":
+		return map[string]any{"type": "string"}
+	}
+	return messageSchema(fd.Message(), schemas)
+}
+
+func jsonContent(schema map[string]any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
+
+func ref(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
@@ -20,11 +20,15 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	UserService_GetUserById_FullMethodName     = "/auth_microservice.UserService/GetUserById"
-	UserService_GetUserForLogin_FullMethodName = "/auth_microservice.UserService/GetUserForLogin"
-	UserService_CreateUser_FullMethodName      = "/auth_microservice.UserService/CreateUser"
-	UserService_UpdateUser_FullMethodName      = "/auth_microservice.UserService/UpdateUser"
-	UserService_DeleteUser_FullMethodName      = "/auth_microservice.UserService/DeleteUser"
+	UserService_GetUserById_FullMethodName            = "/auth_microservice.UserService/GetUserById"
+	UserService_GetUserForLogin_FullMethodName        = "/auth_microservice.UserService/GetUserForLogin"
+	UserService_VerifyCredentials_FullMethodName      = "/auth_microservice.UserService/VerifyCredentials"
+	UserService_CreateUser_FullMethodName             = "/auth_microservice.UserService/CreateUser"
+	UserService_UpdateUser_FullMethodName             = "/auth_microservice.UserService/UpdateUser"
+	UserService_DeleteUser_FullMethodName             = "/auth_microservice.UserService/DeleteUser"
+	UserService_ListDeadLetterEmails_FullMethodName   = "/auth_microservice.UserService/ListDeadLetterEmails"
+	UserService_RetryDeadLetterEmail_FullMethodName   = "/auth_microservice.UserService/RetryDeadLetterEmail"
+	UserService_DiscardDeadLetterEmail_FullMethodName = "/auth_microservice.UserService/DiscardDeadLetterEmail"
 )
 
 // UserServiceClient is the client API for UserService service.
@@ -33,9 +37,13 @@ const (
 type UserServiceClient interface {
 	GetUserById(ctx context.Context, in *GetUserRequest, opts ...grpc.CallOption) (*User, error)
 	GetUserForLogin(ctx context.Context, in *GetUserForLoginRequest, opts ...grpc.CallOption) (*UserForLogin, error)
+	VerifyCredentials(ctx context.Context, in *VerifyCredentialsRequest, opts ...grpc.CallOption) (*User, error)
 	CreateUser(ctx context.Context, in *CreateUserRequest, opts ...grpc.CallOption) (*User, error)
 	UpdateUser(ctx context.Context, in *UpdateUserRequest, opts ...grpc.CallOption) (*User, error)
 	DeleteUser(ctx context.Context, in *DeleteUserRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	ListDeadLetterEmails(ctx context.Context, in *ListDeadLetterEmailsRequest, opts ...grpc.CallOption) (*ListDeadLetterEmailsResponse, error)
+	RetryDeadLetterEmail(ctx context.Context, in *DeadLetterEmailRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	DiscardDeadLetterEmail(ctx context.Context, in *DeadLetterEmailRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 }
 
 type userServiceClient struct {
@@ -66,6 +74,16 @@ func (c *userServiceClient) GetUserForLogin(ctx context.Context, in *GetUserForL
 	return out, nil
 }
 
+func (c *userServiceClient) VerifyCredentials(ctx context.Context, in *VerifyCredentialsRequest, opts ...grpc.CallOption) (*User, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(User)
+	err := c.cc.Invoke(ctx, UserService_VerifyCredentials_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *userServiceClient) CreateUser(ctx context.Context, in *CreateUserRequest, opts ...grpc.CallOption) (*User, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(User)
@@ -96,15 +114,49 @@ func (c *userServiceClient) DeleteUser(ctx context.Context, in *DeleteUserReques
 	return out, nil
 }
 
+func (c *userServiceClient) ListDeadLetterEmails(ctx context.Context, in *ListDeadLetterEmailsRequest, opts ...grpc.CallOption) (*ListDeadLetterEmailsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListDeadLetterEmailsResponse)
+	err := c.cc.Invoke(ctx, UserService_ListDeadLetterEmails_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) RetryDeadLetterEmail(ctx context.Context, in *DeadLetterEmailRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, UserService_RetryDeadLetterEmail_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) DiscardDeadLetterEmail(ctx context.Context, in *DeadLetterEmailRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, UserService_DiscardDeadLetterEmail_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // UserServiceServer is the server API for UserService service.
 // All implementations must embed UnimplementedUserServiceServer
 // for forward compatibility.
 type UserServiceServer interface {
 	GetUserById(context.Context, *GetUserRequest) (*User, error)
 	GetUserForLogin(context.Context, *GetUserForLoginRequest) (*UserForLogin, error)
+	VerifyCredentials(context.Context, *VerifyCredentialsRequest) (*User, error)
 	CreateUser(context.Context, *CreateUserRequest) (*User, error)
 	UpdateUser(context.Context, *UpdateUserRequest) (*User, error)
 	DeleteUser(context.Context, *DeleteUserRequest) (*emptypb.Empty, error)
+	ListDeadLetterEmails(context.Context, *ListDeadLetterEmailsRequest) (*ListDeadLetterEmailsResponse, error)
+	RetryDeadLetterEmail(context.Context, *DeadLetterEmailRequest) (*emptypb.Empty, error)
+	DiscardDeadLetterEmail(context.Context, *DeadLetterEmailRequest) (*emptypb.Empty, error)
 	mustEmbedUnimplementedUserServiceServer()
 }
 
@@ -121,6 +173,9 @@ func (UnimplementedUserServiceServer) GetUserById(context.Context, *GetUserReque
 func (UnimplementedUserServiceServer) GetUserForLogin(context.Context, *GetUserForLoginRequest) (*UserForLogin, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetUserForLogin not implemented")
 }
+func (UnimplementedUserServiceServer) VerifyCredentials(context.Context, *VerifyCredentialsRequest) (*User, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VerifyCredentials not implemented")
+}
 func (UnimplementedUserServiceServer) CreateUser(context.Context, *CreateUserRequest) (*User, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CreateUser not implemented")
 }
@@ -130,6 +185,15 @@ func (UnimplementedUserServiceServer) UpdateUser(context.Context, *UpdateUserReq
 func (UnimplementedUserServiceServer) DeleteUser(context.Context, *DeleteUserRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteUser not implemented")
 }
+func (UnimplementedUserServiceServer) ListDeadLetterEmails(context.Context, *ListDeadLetterEmailsRequest) (*ListDeadLetterEmailsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDeadLetterEmails not implemented")
+}
+func (UnimplementedUserServiceServer) RetryDeadLetterEmail(context.Context, *DeadLetterEmailRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RetryDeadLetterEmail not implemented")
+}
+func (UnimplementedUserServiceServer) DiscardDeadLetterEmail(context.Context, *DeadLetterEmailRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DiscardDeadLetterEmail not implemented")
+}
 func (UnimplementedUserServiceServer) mustEmbedUnimplementedUserServiceServer() {}
 func (UnimplementedUserServiceServer) testEmbeddedByValue()                     {}
 
@@ -187,6 +251,24 @@ func _UserService_GetUserForLogin_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _UserService_VerifyCredentials_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyCredentialsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).VerifyCredentials(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_VerifyCredentials_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).VerifyCredentials(ctx, req.(*VerifyCredentialsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _UserService_CreateUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(CreateUserRequest)
 	if err := dec(in); err != nil {
@@ -241,6 +323,60 @@ func _UserService_DeleteUser_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _UserService_ListDeadLetterEmails_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDeadLetterEmailsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).ListDeadLetterEmails(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_ListDeadLetterEmails_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).ListDeadLetterEmails(ctx, req.(*ListDeadLetterEmailsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_RetryDeadLetterEmail_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeadLetterEmailRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).RetryDeadLetterEmail(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_RetryDeadLetterEmail_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).RetryDeadLetterEmail(ctx, req.(*DeadLetterEmailRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_DiscardDeadLetterEmail_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeadLetterEmailRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).DiscardDeadLetterEmail(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_DiscardDeadLetterEmail_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).DiscardDeadLetterEmail(ctx, req.(*DeadLetterEmailRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // UserService_ServiceDesc is the grpc.ServiceDesc for UserService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -256,6 +392,10 @@ var UserService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetUserForLogin",
 			Handler:    _UserService_GetUserForLogin_Handler,
 		},
+		{
+			MethodName: "VerifyCredentials",
+			Handler:    _UserService_VerifyCredentials_Handler,
+		},
 		{
 			MethodName: "CreateUser",
 			Handler:    _UserService_CreateUser_Handler,
@@ -268,6 +408,18 @@ var UserService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteUser",
 			Handler:    _UserService_DeleteUser_Handler,
 		},
+		{
+			MethodName: "ListDeadLetterEmails",
+			Handler:    _UserService_ListDeadLetterEmails_Handler,
+		},
+		{
+			MethodName: "RetryDeadLetterEmail",
+			Handler:    _UserService_RetryDeadLetterEmail_Handler,
+		},
+		{
+			MethodName: "DiscardDeadLetterEmail",
+			Handler:    _UserService_DiscardDeadLetterEmail_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "user.proto",
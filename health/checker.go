@@ -0,0 +1,185 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// LivenessService reports whether the process is up. It stays SERVING until
+	// shutdown regardless of dependencies, so a database outage never gets the
+	// pod restarted.
+	LivenessService = "liveness"
+	// ReadinessService reports whether the dependencies are reachable.
+	ReadinessService = "readiness"
+
+	checkTimeout = 5 * time.Second
+)
+
+// Check reports whether a dependency is usable.
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker runs dependency checks periodically and publishes the result through the
+// gRPC health service and the optional HTTP probes.
+type Checker struct {
+	server   *grpchealth.Server
+	interval time.Duration
+	// services follow readiness, e.g. the fully qualified name of the user service.
+	services []string
+	checks   []namedCheck
+
+	mu       sync.RWMutex
+	failures map[string]error
+	shutdown bool
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewChecker(interval time.Duration, services ...string) *Checker {
+	c := &Checker{
+		server:   grpchealth.NewServer(),
+		interval: interval,
+		services: append([]string{"", ReadinessService}, services...),
+		failures: map[string]error{},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	c.server.SetServingStatus(LivenessService, grpc_health_v1.HealthCheckResponse_SERVING)
+	c.setReadiness(grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	return c
+}
+
+// AddCheck must be called before Start.
+func (c *Checker) AddCheck(name string, check Check) {
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+func (c *Checker) Register(s *grpc.Server) {
+	grpc_health_v1.RegisterHealthServer(s, c.server)
+}
+
+// Start runs the checks once synchronously and then every interval.
+func (c *Checker) Start() {
+	c.runChecks()
+
+	go func() {
+		defer close(c.done)
+
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+				c.runChecks()
+			}
+		}
+	}()
+}
+
+func (c *Checker) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+		<-c.done
+	})
+}
+
+func (c *Checker) runChecks() {
+	failures := map[string]error{}
+	for _, nc := range c.checks {
+		ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+		if err := nc.check(ctx); err != nil {
+			failures[nc.name] = err
+		}
+		cancel()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name, err := range failures {
+		if _, failing := c.failures[name]; !failing {
+			slog.Warn("Health check failed", "check", name, "error", err)
+		}
+	}
+	for name := range c.failures {
+		if _, failing := failures[name]; !failing {
+			slog.Info("Health check recovered", "check", name)
+		}
+	}
+	c.failures = failures
+
+	if c.shutdown {
+		return
+	}
+	if len(failures) == 0 {
+		c.setReadiness(grpc_health_v1.HealthCheckResponse_SERVING)
+	} else {
+		c.setReadiness(grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	}
+}
+
+func (c *Checker) setReadiness(status grpc_health_v1.HealthCheckResponse_ServingStatus) {
+	for _, service := range c.services {
+		c.server.SetServingStatus(service, status)
+	}
+}
+
+// Shutdown reports every service as NOT_SERVING from now on, so that load
+// balancers stop sending new requests while in-flight ones are drained.
+func (c *Checker) Shutdown() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.shutdown = true
+	c.server.Shutdown()
+}
+
+// Ready reports nil when every check passed on the last run.
+func (c *Checker) Ready() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.shutdown {
+		return fmt.Errorf("shutting down")
+	}
+	for name, err := range c.failures {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// HTTPHandler serves /healthz for liveness and /readyz for readiness probes.
+func (c *Checker) HTTPHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		if err := c.Ready(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "ok")
+	})
+	return mux
+}
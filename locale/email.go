@@ -0,0 +1,51 @@
+package locale
+
+import (
+	"golang.org/x/text/language"
+)
+
+type EmailContent struct {
+	Subject string
+	Body    string
+}
+
+var welcomeEmails = map[language.Tag]EmailContent{
+	language.English: {
+		Subject: "Welcome to RYG",
+		Body:    "Welcome to RYG, we are glad to have you on board!",
+	},
+	language.Russian: {
+		Subject: "Добро пожаловать в RYG",
+		Body:    "Добро пожаловать в RYG, мы рады видеть вас с нами!",
+	},
+	language.Uzbek: {
+		Subject: "RYG ga xush kelibsiz",
+		Body:    "RYG ga xush kelibsiz, sizni safimizda ko'rganimizdan xursandmiz!",
+	},
+}
+
+// supportedLocales lists the locales with email content, the default locale first
+// so that the matcher falls back to it.
+var supportedLocales = []language.Tag{
+	language.English,
+	language.Russian,
+	language.Uzbek,
+}
+
+var matcher = language.NewMatcher(supportedLocales)
+
+func WelcomeEmail(locale string) EmailContent {
+	return welcomeEmails[match(locale)]
+}
+
+func match(locale string) language.Tag {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return supportedLocales[0]
+	}
+	_, index, confidence := matcher.Match(tag)
+	if confidence == language.No {
+		return supportedLocales[0]
+	}
+	return supportedLocales[index]
+}
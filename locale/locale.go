@@ -0,0 +1,41 @@
+package locale
+
+import (
+	"fmt"
+	"golang.org/x/text/language"
+	"time"
+	_ "time/tzdata"
+)
+
+const (
+	DefaultLocale   = "en"
+	DefaultTimezone = "UTC"
+
+	// MaxLocaleLength is the size of the locale column of users.
+	MaxLocaleLength = 35
+)
+
+// NormalizeLocale parses a BCP 47 language tag and returns its canonical form,
+// which must fit in MaxLocaleLength.
+func NormalizeLocale(locale string) (string, error) {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return "", fmt.Errorf("invalid locale %q: %w", locale, err)
+	}
+	normalized := tag.String()
+	if len(normalized) > MaxLocaleLength {
+		return "", fmt.Errorf("locale %q is longer than %d characters", normalized, MaxLocaleLength)
+	}
+	return normalized, nil
+}
+
+// ValidateTimezone checks that timezone is a known IANA time zone name.
+func ValidateTimezone(timezone string) error {
+	if timezone == "" || timezone == "Local" {
+		return fmt.Errorf("invalid timezone %q", timezone)
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return fmt.Errorf("invalid timezone %q: %w", timezone, err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package logging
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"log/slog"
+	"time"
+)
+
+// RequestIDHeader is read from incoming metadata and echoed in the response
+// header; a new id is generated when the caller didn't send one.
+const RequestIDHeader = "x-request-id"
+
+// UnaryServerInterceptor puts the request id in the context and logs every RPC.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		id := ""
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(RequestIDHeader); len(values) > 0 {
+				id = values[0]
+			}
+		}
+		if id == "" {
+			id = uuid.NewString()
+		}
+		ctx = WithRequestID(ctx, id)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, id))
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		attrs := []any{"method", info.FullMethod, "duration", time.Since(start)}
+		if err != nil {
+			slog.WarnContext(ctx, "RPC failed", append(attrs, "error", err)...)
+		} else {
+			slog.DebugContext(ctx, "RPC handled", attrs...)
+		}
+		return resp, err
+	}
+}
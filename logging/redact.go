@@ -0,0 +1,59 @@
+package logging
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+var (
+	emailPattern  = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	bcryptPattern = regexp.MustCompile(`\$2[abxy]?\$\d{2}\$[./A-Za-z0-9]{53}`)
+	argon2Pattern = regexp.MustCompile(`\$argon2(id|i|d)\$v=\d+\$m=\d+,t=\d+,p=\d+\$[A-Za-z0-9+/]+\$[A-Za-z0-9+/]+`)
+	// legacyHashPattern matches the imported formats, see password_hash/legacy.go.
+	legacyHashPattern = regexp.MustCompile(`\$(pbkdf2-sha256|sha1-salted)\$[^\s"]+`)
+)
+
+// sensitiveKeys are dropped whatever their value.
+var sensitiveKeys = map[string]bool{
+	"password":      true,
+	"password_hash": true,
+	"hash":          true,
+	"token":         true,
+}
+
+// RedactEmail keeps the first character of the local part and the domain, e.g.
+// j***@example.com, which is usually enough to tell users apart in logs.
+func RedactEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 1 {
+		return redacted
+	}
+	return email[:1] + "***" + email[at:]
+}
+
+// Redact masks the email addresses and password hashes found in s.
+func Redact(s string) string {
+	s = bcryptPattern.ReplaceAllString(s, redacted)
+	s = argon2Pattern.ReplaceAllString(s, redacted)
+	s = legacyHashPattern.ReplaceAllString(s, redacted)
+	return emailPattern.ReplaceAllStringFunc(s, RedactEmail)
+}
+
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Redact(a.Value.String()))
+	case slog.KindAny:
+		// Database errors quote the offending values, e.g. a duplicate email.
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, Redact(err.Error()))
+		}
+	}
+	return a
+}
@@ -0,0 +1,37 @@
+package model
+
+import "time"
+
+type PendingEmail struct {
+	ID            int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	MessageID     string    `json:"message_id" gorm:"type:varchar(36);not null"`
+	To            string    `json:"to" gorm:"column:recipient;not null"`
+	Subject       string    `json:"subject"`
+	Body          string    `json:"body"`
+	Critical      bool      `json:"critical" gorm:"not null;default:false"`
+	Attempts      int       `json:"attempts" gorm:"not null;default:0"`
+	LastError     string    `json:"last_error"`
+	NextAttemptAt time.Time `json:"next_attempt_at" gorm:"not null;index"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (PendingEmail) TableName() string {
+	return "pending_emails"
+}
+
+type DeadLetterEmail struct {
+	ID        int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	MessageID string    `json:"message_id" gorm:"type:varchar(36);not null"`
+	To        string    `json:"to" gorm:"column:recipient;not null"`
+	Subject   string    `json:"subject"`
+	Body      string    `json:"body"`
+	Critical  bool      `json:"critical" gorm:"not null;default:false"`
+	Attempts  int       `json:"attempts" gorm:"not null"`
+	LastError string    `json:"last_error"`
+	CreatedAt time.Time `json:"created_at"`
+	FailedAt  time.Time `json:"failed_at" gorm:"not null"`
+}
+
+func (DeadLetterEmail) TableName() string {
+	return "dead_letter_emails"
+}
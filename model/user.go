@@ -1,14 +1,35 @@
 package model
 
+const (
+	RoleAdmin   = "admin"
+	RoleUser    = "user"
+	RoleProUser = "pro_user"
+)
+
+// Email statuses are set from bounce and complaint events sent by the email service.
+const (
+	EmailStatusDeliverable = "deliverable"
+	EmailStatusBounced     = "bounced"
+	EmailStatusComplained  = "complained"
+)
+
 type User struct {
-	ID       int64  `json:"id" gorm:"primaryKey;autoIncrement"`
-	FullName string `json:"full_name" gorm:"column:full_name"`
-	Password string `json:"password"`
-	Email    string `json:"email" gorm:"unique"`
-	Role     string `json:"role" gorm:"type:varchar(10);check:role IN ('admin', 'user', 'pro_user')"`
-	IsActive bool   `json:"is_active" gorm:"default:true"`
+	ID          int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	FullName    string `json:"full_name" gorm:"column:full_name"`
+	Password    string `json:"password"`
+	Email       string `json:"email" gorm:"uniqueIndex:uni_users_email_lower,expression:lower(email)"`
+	Role        string `json:"role" gorm:"type:varchar(10);check:role IN ('admin', 'user', 'pro_user')"`
+	IsActive    bool   `json:"is_active" gorm:"default:true"`
+	Locale      string `json:"locale" gorm:"type:varchar(35);not null;default:'en'"`
+	Timezone    string `json:"timezone" gorm:"type:varchar(64);not null;default:'UTC'"`
+	EmailStatus string `json:"email_status" gorm:"type:varchar(20);not null;default:'deliverable';check:email_status IN ('deliverable', 'bounced', 'complained')"`
 }
 
 func (User) TableName() string {
 	return "users"
 }
+
+// EmailDeliverable reports whether non-critical emails may be sent to the user.
+func (u *User) EmailDeliverable() bool {
+	return u.EmailStatus == "" || u.EmailStatus == EmailStatusDeliverable
+}
@@ -0,0 +1,104 @@
+package password_hash
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"ryg-user-service/conf"
+	"strings"
+)
+
+const (
+	argon2idSaltLength = 16
+	argon2idKeyLength  = 32
+)
+
+type argon2idParams struct {
+	memory      uint32 // KiB
+	iterations  uint32
+	parallelism uint8
+	keyLength   uint32
+}
+
+func argon2idParamsOf(cnf conf.PasswordHashingConfig) argon2idParams {
+	return argon2idParams{
+		memory:      uint32(cnf.Argon2Memory),
+		iterations:  uint32(cnf.Argon2Iterations),
+		parallelism: uint8(cnf.Argon2Parallelism),
+		keyLength:   argon2idKeyLength,
+	}
+}
+
+type argon2idVerifier struct{}
+
+func (argon2idVerifier) Algorithm() string {
+	return conf.PasswordHashArgon2id
+}
+
+func (argon2idVerifier) Check(encoded string) error {
+	_, _, _, err := decodeArgon2id(encoded)
+	return err
+}
+
+func (argon2idVerifier) Verify(password, encoded string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+	candidate := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, params.keyLength)
+	return subtle.ConstantTimeCompare(candidate, key) == 1, nil
+}
+
+func (argon2idVerifier) Current(encoded string, cnf conf.PasswordHashingConfig) bool {
+	params, _, _, err := decodeArgon2id(encoded)
+	return err == nil && cnf.Algorithm == conf.PasswordHashArgon2id && params == argon2idParamsOf(cnf)
+}
+
+// hashArgon2id encodes the hash in the PHC string format used by the reference
+// implementation, with unpadded base64 salt and key.
+func hashArgon2id(password string, params argon2idParams) (string, error) {
+	salt := make([]byte, argon2idSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, params.keyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.memory, params.iterations, params.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func decodeArgon2id(encoded string) (params argon2idParams, salt, key []byte, err error) {
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, key
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, fmt.Errorf("malformed argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2id version %q", parts[2])
+	}
+	var memory, iterations, parallelism int
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("malformed argon2id parameters %q", parts[3])
+	}
+	// argon2.IDKey panics below these minimums, and the maximums bound the work
+	// of verifying an imported hash.
+	if iterations < 1 || iterations > conf.MaxArgon2Iterations ||
+		parallelism < 1 || parallelism > conf.MaxArgon2Parallelism ||
+		memory < 8*parallelism || memory > conf.MaxArgon2Memory {
+		return params, nil, nil, fmt.Errorf("unsupported argon2id parameters %q", parts[3])
+	}
+	params.memory, params.iterations, params.parallelism = uint32(memory), uint32(iterations), uint8(parallelism)
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, nil, nil, fmt.Errorf("malformed argon2id salt: %w", err)
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(key) < 4 {
+		return params, nil, nil, fmt.Errorf("malformed argon2id key")
+	}
+	params.keyLength = uint32(len(key))
+	return params, salt, key, nil
+}
@@ -0,0 +1,43 @@
+package password_hash
+
+import (
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+	"ryg-user-service/conf"
+)
+
+type bcryptVerifier struct{}
+
+func (bcryptVerifier) Algorithm() string {
+	return conf.PasswordHashBcrypt
+}
+
+func (bcryptVerifier) Check(encoded string) error {
+	_, err := bcrypt.Cost([]byte(encoded))
+	return err
+}
+
+func (bcryptVerifier) Verify(password, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+func (bcryptVerifier) Current(encoded string, cnf conf.PasswordHashingConfig) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err == nil && cnf.Algorithm == conf.PasswordHashBcrypt && cost == cnf.BcryptCost
+}
+
+func hashBcrypt(password string, cost int) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
@@ -0,0 +1,46 @@
+package password_hash
+
+import (
+	"ryg-user-service/conf"
+)
+
+// Hasher hashes passwords with the configured algorithm and verifies hashes of
+// every registered scheme, so that the algorithm or its cost can change, and
+// hashes can be imported, while older hashes keep working.
+type Hasher struct {
+	cnf conf.PasswordHashingConfig
+}
+
+func New(cnf conf.PasswordHashingConfig) *Hasher {
+	return &Hasher{cnf: cnf}
+}
+
+// Algorithm is the name of the algorithm new hashes are made with.
+func (h *Hasher) Algorithm() string {
+	return h.cnf.Algorithm
+}
+
+// Hash returns a self-describing hash of password, which carries the algorithm
+// and its parameters, e.g. $argon2id$v=19$m=19456,t=2,p=1$<salt>$<key>.
+func (h *Hasher) Hash(password string) (string, error) {
+	if h.cnf.Algorithm == conf.PasswordHashBcrypt {
+		return hashBcrypt(password, h.cnf.BcryptCost)
+	}
+	return hashArgon2id(password, argon2idParamsOf(h.cnf))
+}
+
+// Verify reports whether password matches encoded and, if it does, whether
+// encoded should be replaced by a new hash because it wasn't made the way new
+// hashes are.
+func (h *Hasher) Verify(password, encoded string) (match, needsRehash bool, err error) {
+	v, err := verifierFor(encoded)
+	if err != nil {
+		return false, false, err
+	}
+
+	match, err = v.Verify(password, encoded)
+	if err != nil || !match {
+		return false, false, err
+	}
+	return true, !v.Current(encoded, h.cnf), nil
+}
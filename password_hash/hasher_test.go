@@ -0,0 +1,99 @@
+package password_hash
+
+import (
+	"golang.org/x/crypto/bcrypt"
+	"ryg-user-service/conf"
+	"testing"
+)
+
+var (
+	bcryptConfig = conf.PasswordHashingConfig{
+		Algorithm:  conf.PasswordHashBcrypt,
+		BcryptCost: bcrypt.MinCost,
+	}
+	argon2idConfig = conf.PasswordHashingConfig{
+		Algorithm:         conf.PasswordHashArgon2id,
+		Argon2Memory:      1024,
+		Argon2Iterations:  1,
+		Argon2Parallelism: 1,
+	}
+)
+
+func TestHasherVerify(t *testing.T) {
+	const password = "correct horse battery staple"
+
+	bcryptHash, err := New(bcryptConfig).Hash(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	argon2idHash, err := New(argon2idConfig).Hash(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	higherCost := bcryptConfig
+	higherCost.BcryptCost++
+	moreMemory := argon2idConfig
+	moreMemory.Argon2Memory *= 2
+
+	tests := []struct {
+		name        string
+		cnf         conf.PasswordHashingConfig
+		encoded     string
+		needsRehash bool
+	}{
+		{"bcrypt with the same cost", bcryptConfig, bcryptHash, false},
+		{"bcrypt with a higher cost configured", higherCost, bcryptHash, true},
+		{"bcrypt with argon2id configured", argon2idConfig, bcryptHash, true},
+		{"argon2id with the same parameters", argon2idConfig, argon2idHash, false},
+		{"argon2id with more memory configured", moreMemory, argon2idHash, true},
+		{"argon2id with bcrypt configured", bcryptConfig, argon2idHash, true},
+		{"legacy pbkdf2-sha256", argon2idConfig, "$pbkdf2-sha256$i=1$c2FsdA$VawEblbjCJ/sFpHCJUS2BflBhSFt3gRl5oudV8INrLxJypzM8Xm2RZkWZLOdd+8xfHG4RbHjC9UJESBB06GXgw", true},
+		{"legacy sha1-salted", bcryptConfig, "$sha1-salted$k3yS4lt$951fd598e702e0feb5695c25dd59d490282755e8", true},
+	}
+	passwords := map[string]string{
+		"legacy pbkdf2-sha256": "passwd",
+		"legacy sha1-salted":   "hunter2",
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h := New(tc.cnf)
+			pw := password
+			if p, ok := passwords[tc.name]; ok {
+				pw = p
+			}
+
+			match, needsRehash, err := h.Verify(pw, tc.encoded)
+			if err != nil || !match || needsRehash != tc.needsRehash {
+				t.Errorf("Verify() = %t, %t, %v, want a match and needsRehash %t", match, needsRehash, err, tc.needsRehash)
+			}
+
+			// A wrong password never asks for a rehash.
+			match, needsRehash, err = h.Verify("x"+pw, tc.encoded)
+			if err != nil || match || needsRehash {
+				t.Errorf("Verify(wrong password) = %t, %t, %v, want no match", match, needsRehash, err)
+			}
+		})
+	}
+}
+
+func TestHasherHashFormat(t *testing.T) {
+	for _, cnf := range []conf.PasswordHashingConfig{bcryptConfig, argon2idConfig} {
+		encoded, err := New(cnf).Hash("password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := AlgorithmOf(encoded); got != cnf.Algorithm {
+			t.Errorf("AlgorithmOf(%q) = %q, want %q", encoded, got, cnf.Algorithm)
+		}
+
+		again, err := New(cnf).Hash("password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again == encoded {
+			t.Errorf("two %s hashes of the same password are equal, want distinct salts", cnf.Algorithm)
+		}
+	}
+}
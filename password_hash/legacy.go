@@ -0,0 +1,111 @@
+package password_hash
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"golang.org/x/crypto/pbkdf2"
+	"ryg-user-service/conf"
+	"strconv"
+	"strings"
+)
+
+// Hashes imported from the previous system are never current, so every user who
+// logs in moves to the configured algorithm.
+
+// maxPBKDF2Iterations bounds the work of verifying an imported hash.
+const maxPBKDF2Iterations = 10_000_000
+
+// pbkdf2SHA256Verifier checks $pbkdf2-sha256$i=<iterations>$<salt>$<key>, with
+// the salt and the key in base64. The iterations may be given without i=, and
+// passlib's base64 variant with . for + is accepted too.
+type pbkdf2SHA256Verifier struct{}
+
+func (pbkdf2SHA256Verifier) Algorithm() string {
+	return "pbkdf2-sha256"
+}
+
+func (pbkdf2SHA256Verifier) Check(encoded string) error {
+	_, _, _, err := decodePBKDF2(encoded)
+	return err
+}
+
+func (pbkdf2SHA256Verifier) Verify(password, encoded string) (bool, error) {
+	iterations, salt, key, err := decodePBKDF2(encoded)
+	if err != nil {
+		return false, err
+	}
+	candidate := pbkdf2.Key([]byte(password), salt, iterations, len(key), sha256.New)
+	return subtle.ConstantTimeCompare(candidate, key) == 1, nil
+}
+
+func (pbkdf2SHA256Verifier) Current(string, conf.PasswordHashingConfig) bool {
+	return false
+}
+
+func decodePBKDF2(encoded string) (iterations int, salt, key []byte, err error) {
+	// "", "pbkdf2-sha256", iterations, salt, key
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 5 {
+		return 0, nil, nil, fmt.Errorf("malformed pbkdf2-sha256 hash")
+	}
+
+	iterations, err = strconv.Atoi(strings.TrimPrefix(parts[2], "i="))
+	if err != nil || iterations < 1 || iterations > maxPBKDF2Iterations {
+		return 0, nil, nil, fmt.Errorf("malformed pbkdf2-sha256 iterations %q", parts[2])
+	}
+	if salt, err = decodeLegacyBase64(parts[3]); err != nil {
+		return 0, nil, nil, fmt.Errorf("malformed pbkdf2-sha256 salt: %w", err)
+	}
+	if key, err = decodeLegacyBase64(parts[4]); err != nil || len(key) == 0 {
+		return 0, nil, nil, fmt.Errorf("malformed pbkdf2-sha256 key")
+	}
+	return iterations, salt, key, nil
+}
+
+func decodeLegacyBase64(s string) ([]byte, error) {
+	s = strings.ReplaceAll(strings.TrimRight(s, "="), ".", "+")
+	return base64.RawStdEncoding.DecodeString(s)
+}
+
+// saltedSHA1Verifier checks $sha1-salted$<salt>$<digest>, where the digest is
+// the hex SHA-1 of the salt followed by the password.
+type saltedSHA1Verifier struct{}
+
+func (saltedSHA1Verifier) Algorithm() string {
+	return "sha1-salted"
+}
+
+func (saltedSHA1Verifier) Check(encoded string) error {
+	_, _, err := decodeSaltedSHA1(encoded)
+	return err
+}
+
+func (saltedSHA1Verifier) Verify(password, encoded string) (bool, error) {
+	salt, digest, err := decodeSaltedSHA1(encoded)
+	if err != nil {
+		return false, err
+	}
+	candidate := sha1.Sum([]byte(salt + password))
+	return subtle.ConstantTimeCompare(candidate[:], digest) == 1, nil
+}
+
+func (saltedSHA1Verifier) Current(string, conf.PasswordHashingConfig) bool {
+	return false
+}
+
+func decodeSaltedSHA1(encoded string) (salt string, digest []byte, err error) {
+	// "", "sha1-salted", salt, digest
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 {
+		return "", nil, fmt.Errorf("malformed sha1-salted hash")
+	}
+	digest, err = hex.DecodeString(parts[3])
+	if err != nil || len(digest) != sha1.Size {
+		return "", nil, fmt.Errorf("malformed sha1-salted digest")
+	}
+	return parts[2], digest, nil
+}
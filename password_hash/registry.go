@@ -0,0 +1,69 @@
+package password_hash
+
+import (
+	"errors"
+	"ryg-user-service/conf"
+	"strings"
+)
+
+var ErrUnknownFormat = errors.New("unknown password hash format")
+
+// Verifier checks passwords against the hashes of one scheme.
+type Verifier interface {
+	// Algorithm names the algorithm, e.g. for metrics; bcrypt has several schemes.
+	Algorithm() string
+	// Check reports whether encoded is well formed, without a password.
+	Check(encoded string) error
+	Verify(password, encoded string) (bool, error)
+	// Current reports whether encoded was made the way cnf makes new hashes.
+	// Hashes that aren't are replaced on the user's next successful login.
+	Current(encoded string, cnf conf.PasswordHashingConfig) bool
+}
+
+// verifiers maps the scheme of a hash, the text between its first two $, to the
+// verifier of that scheme.
+var verifiers = map[string]Verifier{
+	"argon2id":      argon2idVerifier{},
+	"2a":            bcryptVerifier{},
+	"2b":            bcryptVerifier{},
+	"2y":            bcryptVerifier{},
+	"pbkdf2-sha256": pbkdf2SHA256Verifier{},
+	"sha1-salted":   saltedSHA1Verifier{},
+}
+
+// Register makes the hashes of scheme verifiable, e.g. those imported from
+// another system. It must be called before any hash is verified, e.g. from init.
+func Register(scheme string, v Verifier) {
+	verifiers[scheme] = v
+}
+
+func verifierFor(encoded string) (Verifier, error) {
+	if !strings.HasPrefix(encoded, "$") {
+		return nil, ErrUnknownFormat
+	}
+	scheme, _, found := strings.Cut(encoded[1:], "$")
+	v, ok := verifiers[scheme]
+	if !found || !ok {
+		return nil, ErrUnknownFormat
+	}
+	return v, nil
+}
+
+// AlgorithmOf names the algorithm encoded was made with, or is empty if the
+// format is unknown.
+func AlgorithmOf(encoded string) string {
+	v, err := verifierFor(encoded)
+	if err != nil {
+		return ""
+	}
+	return v.Algorithm()
+}
+
+// Check reports whether encoded is a well-formed hash of a known scheme.
+func Check(encoded string) error {
+	v, err := verifierFor(encoded)
+	if err != nil {
+		return err
+	}
+	return v.Check(encoded)
+}
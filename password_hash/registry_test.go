@@ -0,0 +1,147 @@
+package password_hash
+
+import (
+	"errors"
+	"testing"
+)
+
+// Known answers from outside this package: the argon2id example of the reference
+// implementation, the crypt_blowfish test vectors, the PBKDF2-HMAC-SHA256 vectors
+// of RFC 7914 section 11, and a SHA-1 digest computed with Python's hashlib.
+var knownAnswers = []struct {
+	name      string
+	encoded   string
+	password  string
+	algorithm string
+}{
+	{
+		name:      "argon2id",
+		encoded:   "$argon2id$v=19$m=65536,t=2,p=4$c29tZXNhbHQ$GpZ3sK/oH9p7VIiV56G/64Zo/8GaUw434IimaPqxwCo",
+		password:  "password",
+		algorithm: "argon2id",
+	},
+	{
+		name:      "bcrypt 2a",
+		encoded:   "$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW",
+		password:  "U*U",
+		algorithm: "bcrypt",
+	},
+	{
+		name:      "bcrypt 2a truncated at 72 bytes",
+		encoded:   "$2a$05$abcdefghijklmnopqrstuu5s2v8.iXieOjg/.AySBTTZIIVFJeBui",
+		password:  "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789chars after 72 are ignored",
+		algorithm: "bcrypt",
+	},
+	{
+		name:      "bcrypt 2b",
+		encoded:   "$2b$05$/OK.fbVrR/bpIqNJ5ianF.CE5elHaaO4EbggVDjb8P19RukzXSM3e",
+		password:  "\xff\xff\xa3",
+		algorithm: "bcrypt",
+	},
+	{
+		name:      "bcrypt 2y",
+		encoded:   "$2y$05$/OK.fbVrR/bpIqNJ5ianF.CE5elHaaO4EbggVDjb8P19RukzXSM3e",
+		password:  "\xff\xff\xa3",
+		algorithm: "bcrypt",
+	},
+	{
+		name:      "pbkdf2-sha256",
+		encoded:   "$pbkdf2-sha256$i=1$c2FsdA$VawEblbjCJ/sFpHCJUS2BflBhSFt3gRl5oudV8INrLxJypzM8Xm2RZkWZLOdd+8xfHG4RbHjC9UJESBB06GXgw",
+		password:  "passwd",
+		algorithm: "pbkdf2-sha256",
+	},
+	{
+		name:      "pbkdf2-sha256 padded without i=",
+		encoded:   "$pbkdf2-sha256$1$c2FsdA==$VawEblbjCJ/sFpHCJUS2BflBhSFt3gRl5oudV8INrLxJypzM8Xm2RZkWZLOdd+8xfHG4RbHjC9UJESBB06GXgw==",
+		password:  "passwd",
+		algorithm: "pbkdf2-sha256",
+	},
+	{
+		name:      "pbkdf2-sha256 passlib base64",
+		encoded:   "$pbkdf2-sha256$80000$TmFDbA$TdzY9guYviGDDO5e8icB.WQaRBjQTAQUrv8Ih2s0q1ah1CWhIlgzVJrbhBtRybMXaicr3ruh0HhHj2Kzl/M8jQ",
+		password:  "Password",
+		algorithm: "pbkdf2-sha256",
+	},
+	{
+		name:      "sha1-salted",
+		encoded:   "$sha1-salted$k3yS4lt$951fd598e702e0feb5695c25dd59d490282755e8",
+		password:  "hunter2",
+		algorithm: "sha1-salted",
+	},
+}
+
+func TestVerifiersKnownAnswers(t *testing.T) {
+	for _, tc := range knownAnswers {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := AlgorithmOf(tc.encoded); got != tc.algorithm {
+				t.Errorf("AlgorithmOf() = %q, want %q", got, tc.algorithm)
+			}
+			if err := Check(tc.encoded); err != nil {
+				t.Errorf("Check() failed: %v", err)
+			}
+
+			v, err := verifierFor(tc.encoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			match, err := v.Verify(tc.password, tc.encoded)
+			if err != nil || !match {
+				t.Errorf("Verify(%q) = %t, %v, want a match", tc.password, match, err)
+			}
+			match, err = v.Verify("x"+tc.password, tc.encoded)
+			if err != nil || match {
+				t.Errorf("Verify(wrong password) = %t, %v, want no match", match, err)
+			}
+		})
+	}
+}
+
+func TestCheckMalformed(t *testing.T) {
+	for _, encoded := range []string{
+		"$argon2id$v=16$m=65536,t=2,p=4$c29tZXNhbHQ$GpZ3sK/oH9p7VIiV56G/64Zo/8GaUw434IimaPqxwCo",
+		"$argon2id$v=19$m=65536,t=2$c29tZXNhbHQ$GpZ3sK/oH9p7VIiV56G/64Zo/8GaUw434IimaPqxwCo",
+		// Parameters argon2.IDKey panics on, and ones too expensive to verify.
+		"$argon2id$v=19$m=0,t=0,p=0$c29tZXNhbHQ$GpZ3sK/oH9p7VIiV56G/64Zo/8GaUw434IimaPqxwCo",
+		"$argon2id$v=19$m=65536,t=0,p=4$c29tZXNhbHQ$GpZ3sK/oH9p7VIiV56G/64Zo/8GaUw434IimaPqxwCo",
+		"$argon2id$v=19$m=65536,t=2,p=0$c29tZXNhbHQ$GpZ3sK/oH9p7VIiV56G/64Zo/8GaUw434IimaPqxwCo",
+		"$argon2id$v=19$m=31,t=2,p=4$c29tZXNhbHQ$GpZ3sK/oH9p7VIiV56G/64Zo/8GaUw434IimaPqxwCo",
+		"$argon2id$v=19$m=-65536,t=2,p=4$c29tZXNhbHQ$GpZ3sK/oH9p7VIiV56G/64Zo/8GaUw434IimaPqxwCo",
+		"$argon2id$v=19$m=4294967295,t=2,p=4$c29tZXNhbHQ$GpZ3sK/oH9p7VIiV56G/64Zo/8GaUw434IimaPqxwCo",
+		"$argon2id$v=19$m=65536,t=4294967295,p=4$c29tZXNhbHQ$GpZ3sK/oH9p7VIiV56G/64Zo/8GaUw434IimaPqxwCo",
+		"$argon2id$v=19$m=65536,t=2,p=255$c29tZXNhbHQ$GpZ3sK/oH9p7VIiV56G/64Zo/8GaUw434IimaPqxwCo",
+		"$argon2id$v=19$m=65536,t=2,p=4$c29tZXNhbHQ$",
+		"$2a$05$tooshort",
+		"$pbkdf2-sha256$i=0$c2FsdA$VawEblbjCJ/sFpHCJUS2Bw",
+		"$pbkdf2-sha256$i=1$c2FsdA$",
+		"$pbkdf2-sha256$i=2147483647$c2FsdA$VawEblbjCJ/sFpHCJUS2Bw",
+		"$pbkdf2-sha256$-1$c2FsdA$VawEblbjCJ/sFpHCJUS2Bw",
+		"$sha1-salted$k3yS4lt$not-hex",
+		"$sha1-salted$k3yS4lt$951fd598",
+	} {
+		if err := Check(encoded); err == nil {
+			t.Errorf("Check(%q) succeeded, want an error", encoded)
+		}
+	}
+}
+
+// TestVerifyRejectsMalformed makes sure Verify fails on a hash Check rejects
+// instead of handing it to the key derivation, which panics on some parameters.
+func TestVerifyRejectsMalformed(t *testing.T) {
+	h := New(argon2idConfig)
+	for _, encoded := range []string{
+		"$argon2id$v=19$m=0,t=0,p=0$c29tZXNhbHQ$GpZ3sK/oH9p7VIiV56G/64Zo/8GaUw434IimaPqxwCo",
+		"$pbkdf2-sha256$i=2147483647$c2FsdA$VawEblbjCJ/sFpHCJUS2Bw",
+	} {
+		if match, _, err := h.Verify("password", encoded); err == nil || match {
+			t.Errorf("Verify(%q) = %t, %v, want an error", encoded, match, err)
+		}
+	}
+}
+
+func TestCheckUnknownFormat(t *testing.T) {
+	for _, encoded := range []string{"", "hunter2", "$md5$abc$def"} {
+		if err := Check(encoded); !errors.Is(err, ErrUnknownFormat) {
+			t.Errorf("Check(%q) = %v, want ErrUnknownFormat", encoded, err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package password_policy
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// rangeFileName matches the files of the k-anonymity range layout, one per
+// 5-character hash prefix, e.g. 5BAA6.txt.
+var rangeFileName = regexp.MustCompile(`^[0-9A-Fa-f]{5}\.txt$`)
+
+// BreachedList holds the SHA-1 hashes of breached passwords, sorted so that a
+// lookup is a binary search.
+type BreachedList struct {
+	hashes [][sha1.Size]byte
+}
+
+// LoadBreachedList reads the hashes written by the Pwned Passwords downloader,
+// usually a subset such as the most common ones. path is either a single file
+// with a full hex SHA-1 hash per line, or a directory of range files named by
+// hash prefix with the remaining 35 characters per line. Lines may end in :count.
+func LoadBreachedList(path string) (*BreachedList, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password list: %w", err)
+	}
+
+	var hashes [][sha1.Size]byte
+	if !info.IsDir() {
+		hashes, err = readHashFile(path, "", hashes)
+	} else {
+		var entries []os.DirEntry
+		entries, err = os.ReadDir(path)
+		for _, entry := range entries {
+			if err != nil {
+				break
+			}
+			if entry.IsDir() || !rangeFileName.MatchString(entry.Name()) {
+				continue
+			}
+			prefix := strings.TrimSuffix(entry.Name(), ".txt")
+			hashes, err = readHashFile(filepath.Join(path, entry.Name()), prefix, hashes)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(hashes, func(i, j int) bool {
+		return bytes.Compare(hashes[i][:], hashes[j][:]) < 0
+	})
+	slog.Info("Loaded breached password hashes", "count", len(hashes))
+	return &BreachedList{hashes: hashes}, nil
+}
+
+func readHashFile(path, prefix string, hashes [][sha1.Size]byte) ([][sha1.Size]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password list: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if i := strings.IndexByte(text, ':'); i >= 0 {
+			text = text[:i]
+		}
+
+		var hash [sha1.Size]byte
+		// hex.Decode panics when the input doesn't fit hash.
+		encoded := prefix + text
+		if len(encoded) != hex.EncodedLen(sha1.Size) {
+			return nil, fmt.Errorf("breached password list %s:%d: not a SHA-1 hash", path, line)
+		}
+		if _, err := hex.Decode(hash[:], []byte(encoded)); err != nil {
+			return nil, fmt.Errorf("breached password list %s:%d: not a SHA-1 hash", path, line)
+		}
+		hashes = append(hashes, hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read breached password list: %w", err)
+	}
+	return hashes, nil
+}
+
+func (l *BreachedList) Contains(password string) bool {
+	hash := sha1.Sum([]byte(password))
+	i := sort.Search(len(l.hashes), func(i int) bool {
+		return bytes.Compare(l.hashes[i][:], hash[:]) >= 0
+	})
+	return i < len(l.hashes) && l.hashes[i] == hash
+}
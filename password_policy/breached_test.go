@@ -0,0 +1,96 @@
+package password_policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The SHA-1 hashes of "password" and "letmein".
+const (
+	passwordHash = "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8"
+	letmeinHash  = "B7A875FC1EA228B9061041B7CEC4BD3C52AB3CE3"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadBreachedList(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		// path is relative to the directory the files are written to.
+		path string
+	}{
+		{
+			name:  "single file",
+			files: map[string]string{"hashes.txt": passwordHash + "\n\n" + letmeinHash + "\n"},
+			path:  "hashes.txt",
+		},
+		{
+			name:  "single file with counts and lowercase",
+			files: map[string]string{"hashes.txt": "5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8:3861493\r\n" + letmeinHash + ":1\n"},
+			path:  "hashes.txt",
+		},
+		{
+			name: "range directory",
+			files: map[string]string{
+				"5BAA6.txt":  passwordHash[5:] + ":3861493\n",
+				"b7a87.txt":  letmeinHash[5:] + "\n",
+				"README.md":  "not a range file\n",
+				"5BAA6.json": "not a range file either\n",
+			},
+			path: ".",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+
+			l, err := LoadBreachedList(filepath.Join(dir, tc.path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, password := range []string{"password", "letmein"} {
+				if !l.Contains(password) {
+					t.Errorf("Contains(%q) = false, want true", password)
+				}
+			}
+			if l.Contains("correct horse battery staple") {
+				t.Error("Contains() = true for a password not in the list")
+			}
+		})
+	}
+}
+
+func TestLoadBreachedListMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		path  string
+	}{
+		{"truncated hash", map[string]string{"hashes.txt": passwordHash[:39] + "\n"}, "hashes.txt"},
+		{"not hex", map[string]string{"hashes.txt": "not a hash\n"}, "hashes.txt"},
+		// A range file holds the hashes without their prefix.
+		{"full hash in a range file", map[string]string{"5BAA6.txt": passwordHash + "\n"}, "."},
+		{"missing", nil, "missing.txt"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+
+			if _, err := LoadBreachedList(filepath.Join(dir, tc.path)); err == nil {
+				t.Error("LoadBreachedList() succeeded, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package password_policy
+
+import (
+	"fmt"
+	"ryg-user-service/conf"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// minPersonalInfoLength keeps short name parts, e.g. "Li", from rejecting most
+// passwords.
+const minPersonalInfoLength = 3
+
+type Policy struct {
+	cnf      conf.PasswordPolicyConfig
+	breached *BreachedList
+}
+
+// New loads the breached password list, if one is configured.
+func New(cnf conf.PasswordPolicyConfig) (*Policy, error) {
+	p := &Policy{cnf: cnf}
+	if cnf.BreachedListFile != "" {
+		breached, err := LoadBreachedList(cnf.BreachedListFile)
+		if err != nil {
+			return nil, err
+		}
+		p.breached = breached
+	}
+	return p, nil
+}
+
+// Check lists every rule password breaks. email and fullName are those of the
+// user the password is for.
+func (p *Policy) Check(password, email, fullName string) []string {
+	var problems []string
+
+	if utf8.RuneCountInString(password) < p.cnf.MinLength {
+		problems = append(problems, fmt.Sprintf("must be at least %d characters", p.cnf.MinLength))
+	}
+	if len(password) > p.cnf.MaxLength {
+		problems = append(problems, fmt.Sprintf("must be at most %d bytes", p.cnf.MaxLength))
+	}
+	if characterClasses(password) < p.cnf.MinCharacterClasses {
+		problems = append(problems, fmt.Sprintf("must contain at least %d of: lowercase letters, uppercase letters, digits, symbols", p.cnf.MinCharacterClasses))
+	}
+	if p.cnf.DisallowPersonalInfo && containsPersonalInfo(password, email, fullName) {
+		problems = append(problems, "must not contain your email address or name")
+	}
+	if p.breached != nil && p.breached.Contains(password) {
+		problems = append(problems, "appears in a list of breached passwords, choose another one")
+	}
+
+	return problems
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	count := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			count++
+		}
+	}
+	return count
+}
+
+func containsPersonalInfo(password, email, fullName string) bool {
+	password = strings.ToLower(password)
+
+	parts := strings.Fields(strings.ToLower(fullName))
+	if at := strings.LastIndex(email, "@"); at > 0 {
+		parts = append(parts, strings.ToLower(email[:at]))
+	}
+
+	for _, part := range parts {
+		if utf8.RuneCountInString(part) >= minPersonalInfoLength && strings.Contains(password, part) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package password_policy
+
+import (
+	"os"
+	"path/filepath"
+	"ryg-user-service/conf"
+	"strings"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	bcryptPolicy := conf.PasswordPolicyConfig{MinLength: 10, MaxLength: 72, MinCharacterClasses: 2, DisallowPersonalInfo: true}
+	argon2idPolicy := bcryptPolicy
+	argon2idPolicy.MaxLength = 1024
+
+	tests := []struct {
+		name     string
+		cnf      conf.PasswordPolicyConfig
+		password string
+		problems int
+	}{
+		{"valid", bcryptPolicy, "correct horse 9", 0},
+		{"too short", bcryptPolicy, "short 9", 1},
+		// The minimum counts characters, the maximum bytes.
+		{"multibyte at the minimum", bcryptPolicy, "ääääääääää1", 0},
+		{"longer than bcrypt hashes", bcryptPolicy, strings.Repeat("ab1", 25), 1},
+		{"long enough for argon2id", argon2idPolicy, strings.Repeat("ab1", 25), 0},
+		{"longer than argon2id allows", argon2idPolicy, strings.Repeat("ab1", 342), 1},
+		{"one character class", bcryptPolicy, "correcthorsebattery", 1},
+		{"contains the email local part", bcryptPolicy, "jodoe-2024-x", 1},
+		{"contains a name part", bcryptPolicy, "Doakes 1234!", 1},
+		{"short name parts are allowed", bcryptPolicy, "xi-1234567", 0},
+		{"short, one class and personal", bcryptPolicy, "jodoe", 3},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := New(tc.cnf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p.Check(tc.password, "jodoe@example.com", "Xi Doakes"); len(got) != tc.problems {
+				t.Errorf("Check(%q) = %q, want %d problems", tc.password, got, tc.problems)
+			}
+		})
+	}
+}
+
+func TestPolicyCheckBreached(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	// The SHA-1 hashes of "password1234" and "letmein".
+	content := "E6B6AFBD6D76BB5D2041542D7D2E3FAC5BB05593:23\nB7A875FC1EA228B9061041B7CEC4BD3C52AB3CE3:100\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := New(conf.PasswordPolicyConfig{MinLength: 1, MaxLength: 72, BreachedListFile: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if problems := p.Check("password1234", "jo@example.com", "Jo"); len(problems) != 1 {
+		t.Errorf("got %q for a breached password, want one problem", problems)
+	}
+	if problems := p.Check("password12345", "jo@example.com", "Jo"); len(problems) != 0 {
+		t.Errorf("got %q for a password that wasn't breached, want none", problems)
+	}
+}
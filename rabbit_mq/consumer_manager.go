@@ -0,0 +1,312 @@
+package rabbit_mq
+
+import (
+	"context"
+	"errors"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"log/slog"
+	"ryg-user-service/conf"
+	"sync"
+	"time"
+)
+
+const (
+	consumerTag           = "ryg-user-service"
+	consumerPrefetchCount = 10
+
+	minRequeueDelay = time.Second
+	maxRequeueDelay = 30 * time.Second
+)
+
+// Handler processes a single delivery. Returning nil acks the delivery, returning
+// an error wrapped with Permanent rejects it and any other error requeues it
+// after a delay that doubles with every consecutive failure.
+type Handler func(ctx context.Context, delivery amqp.Delivery) error
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks err as not worth retrying, e.g. a message that can't be decoded.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+type binding struct {
+	exchange   string
+	routingKey string
+}
+
+// ConsumerManager owns a connection and a durable queue whose messages are
+// dispatched to handlers by routing key. When the broker closes the connection,
+// the manager reconnects, declares the queue and binds it again.
+type ConsumerManager struct {
+	queueName string
+	bindings  []binding
+	handlers  map[string]Handler
+
+	mu         sync.Mutex
+	cnf        conf.RabbitMQConfig
+	conn       *amqp.Connection
+	ch         *amqp.Channel
+	deliveries <-chan amqp.Delivery
+
+	// requeueDelay is the delay before the next requeue, only used by run.
+	requeueDelay time.Duration
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewConsumerManager(cnf conf.RabbitMQConfig, queueName string) *ConsumerManager {
+	return &ConsumerManager{
+		queueName: queueName,
+		handlers:  map[string]Handler{},
+		cnf:       cnf,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Handle binds the queue to routingKey on exchange and routes its messages to h.
+// It must be called before Start.
+func (m *ConsumerManager) Handle(exchange, routingKey string, h Handler) {
+	m.bindings = append(m.bindings, binding{exchange: exchange, routingKey: routingKey})
+	m.handlers[routingKey] = h
+}
+
+// Start connects and consumes the queue until Close.
+func (m *ConsumerManager) Start() {
+	conn, ch, deliveries, err := m.connect(m.cnf)
+	failOnError(err, "Failed to start consuming")
+
+	m.conn, m.ch, m.deliveries = conn, ch, deliveries
+	go m.run(deliveries)
+	slog.Info("Consuming queue", "queue", m.queueName)
+}
+
+// connect dials the broker, declares and binds the queue and registers the
+// consumer.
+func (m *ConsumerManager) connect(cnf conf.RabbitMQConfig) (*amqp.Connection, *amqp.Channel, <-chan amqp.Delivery, error) {
+	conn, err := amqp.Dial(amqpURL(cnf))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	deliveries, ch, err := m.consume(conn)
+	if err != nil {
+		conn.Close()
+		return nil, nil, nil, err
+	}
+	return conn, ch, deliveries, nil
+}
+
+func (m *ConsumerManager) consume(conn *amqp.Connection) (<-chan amqp.Delivery, *amqp.Channel, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := ch.Qos(consumerPrefetchCount, 0, false); err != nil {
+		return nil, nil, err
+	}
+
+	_, err = ch.QueueDeclare(
+		m.queueName, // queue name
+		true,        // durable
+		false,       // auto-deleted
+		false,       // exclusive
+		false,       // no-wait
+		nil,         // arguments
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, b := range m.bindings {
+		err := ch.QueueBind(
+			m.queueName,  // queue name
+			b.routingKey, // routing key
+			b.exchange,   // exchange name
+			false,        // no-wait
+			nil,          // arguments
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	deliveries, err := ch.Consume(
+		m.queueName, // queue name
+		consumerTag, // consumer tag
+		false,       // auto-ack
+		false,       // exclusive
+		false,       // no-local
+		false,       // no-wait
+		nil,         // arguments
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	return deliveries, ch, nil
+}
+
+// run dispatches deliveries until Close. The deliveries channel is closed when
+// Reconnect replaces the connection, in which case run moves on to the new one,
+// or when the broker closes the connection, in which case run reconnects.
+func (m *ConsumerManager) run(deliveries <-chan amqp.Delivery) {
+	defer close(m.done)
+
+	for {
+		for delivery := range deliveries {
+			m.dispatch(context.Background(), delivery)
+		}
+
+		select {
+		case <-m.stop:
+			return
+		default:
+		}
+
+		m.mu.Lock()
+		replaced := m.deliveries != deliveries
+		deliveries = m.deliveries
+		m.mu.Unlock()
+		if replaced {
+			continue
+		}
+
+		slog.Warn("RabbitMQ consumer connection lost, reconnecting")
+		connected := retryUntil(m.stop, "RabbitMQ consumer", func() error {
+			m.mu.Lock()
+			cnf := m.cnf
+			m.mu.Unlock()
+			return m.Reconnect(cnf)
+		})
+		if !connected {
+			return
+		}
+
+		m.mu.Lock()
+		deliveries = m.deliveries
+		m.mu.Unlock()
+	}
+}
+
+// Reconnect opens a new connection with cnf, e.g. after the password was rotated,
+// consumes the queue from it and closes the old one. The old connection is kept
+// when the new one can't be opened, but cnf is used from now on.
+func (m *ConsumerManager) Reconnect(cnf conf.RabbitMQConfig) error {
+	m.mu.Lock()
+	m.cnf = cnf
+	m.mu.Unlock()
+
+	conn, ch, deliveries, err := m.connect(cnf)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	select {
+	case <-m.stop:
+		// Closed while dialing.
+		m.mu.Unlock()
+		closeQuietly(ch, conn)
+		return nil
+	default:
+	}
+	oldConn, oldCh := m.conn, m.ch
+	m.conn, m.ch, m.deliveries = conn, ch, deliveries
+	m.mu.Unlock()
+
+	// Messages delivered on the old channel and not yet settled are redelivered
+	// by the broker once it is closed.
+	closeQuietly(oldCh, oldConn)
+	slog.Info("Reconnected RabbitMQ consumer")
+	return nil
+}
+
+// Check reports an error while the consumer is disconnected from the broker.
+func (m *ConsumerManager) Check(_ context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.conn.IsClosed() {
+		return errors.New("rabbitmq connection is closed")
+	}
+	if m.ch.IsClosed() {
+		return errors.New("rabbitmq channel is closed")
+	}
+	return nil
+}
+
+func (m *ConsumerManager) dispatch(ctx context.Context, delivery amqp.Delivery) {
+	h, ok := m.handlers[delivery.RoutingKey]
+	if !ok {
+		slog.ErrorContext(ctx, "No handler for routing key, rejecting message", "routing_key", delivery.RoutingKey, "message_id", delivery.MessageId)
+		m.settle(delivery.Reject(false))
+		return
+	}
+
+	err := h(ctx, delivery)
+	var permanent *permanentError
+	switch {
+	case err == nil:
+		m.requeueDelay = 0
+		m.settle(delivery.Ack(false))
+	case errors.As(err, &permanent):
+		slog.ErrorContext(ctx, "Rejecting message", "routing_key", delivery.RoutingKey, "message_id", delivery.MessageId, "error", err)
+		m.settle(delivery.Reject(false))
+	default:
+		// Holding on to the message keeps a failing dependency, e.g. the database
+		// being down, from turning redeliveries into a busy loop.
+		delay := m.nextRequeueDelay()
+		slog.WarnContext(ctx, "Requeueing message", "routing_key", delivery.RoutingKey, "message_id", delivery.MessageId, "delay", delay, "error", err)
+		select {
+		case <-m.stop:
+		case <-time.After(delay):
+		}
+		m.settle(delivery.Nack(false, true))
+	}
+}
+
+func (m *ConsumerManager) nextRequeueDelay() time.Duration {
+	m.requeueDelay = min(max(2*m.requeueDelay, minRequeueDelay), maxRequeueDelay)
+	return m.requeueDelay
+}
+
+func (m *ConsumerManager) settle(err error) {
+	if err != nil {
+		slog.Error("Failed to settle message", "error", err)
+	}
+}
+
+// Close stops consuming, waits for the messages already delivered to be settled
+// and closes the connection.
+func (m *ConsumerManager) Close() {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+	})
+
+	m.mu.Lock()
+	if err := m.ch.Cancel(consumerTag, false); err != nil && !errors.Is(err, amqp.ErrClosed) {
+		slog.Warn("Failed to cancel consumer", "error", err)
+	}
+	m.mu.Unlock()
+
+	<-m.done
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	closeQuietly(m.ch, m.conn)
+}
@@ -0,0 +1,79 @@
+package rabbit_mq
+
+import (
+	"context"
+	"errors"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"ryg-user-service/conf"
+	"testing"
+	"time"
+)
+
+// recordingAcknowledger records how deliveries were settled.
+type recordingAcknowledger struct {
+	settled []string
+}
+
+func (a *recordingAcknowledger) Ack(uint64, bool) error {
+	a.settled = append(a.settled, "ack")
+	return nil
+}
+
+func (a *recordingAcknowledger) Nack(_ uint64, _ bool, requeue bool) error {
+	if requeue {
+		a.settled = append(a.settled, "requeue")
+	} else {
+		a.settled = append(a.settled, "nack")
+	}
+	return nil
+}
+
+func (a *recordingAcknowledger) Reject(uint64, bool) error {
+	a.settled = append(a.settled, "reject")
+	return nil
+}
+
+func TestConsumerManagerDispatch(t *testing.T) {
+	m := NewConsumerManager(conf.RabbitMQConfig{}, "test")
+	var results []error
+	m.Handle(exchangeName, "key", func(context.Context, amqp.Delivery) error {
+		err := results[0]
+		results = results[1:]
+		return err
+	})
+	// Requeues don't wait once the manager is stopping.
+	close(m.stop)
+
+	ack := &recordingAcknowledger{}
+	transient := errors.New("database unavailable")
+	results = []error{transient, transient, nil, Permanent(errors.New("malformed")), transient}
+	for range results {
+		m.dispatch(context.Background(), amqp.Delivery{Acknowledger: ack, RoutingKey: "key"})
+	}
+	m.dispatch(context.Background(), amqp.Delivery{Acknowledger: ack, RoutingKey: "unknown"})
+
+	want := []string{"requeue", "requeue", "ack", "reject", "requeue", "reject"}
+	if len(ack.settled) != len(want) {
+		t.Fatalf("got %v, want %v", ack.settled, want)
+	}
+	for i := range want {
+		if ack.settled[i] != want[i] {
+			t.Fatalf("got %v, want %v", ack.settled, want)
+		}
+	}
+	// The success reset the delay.
+	if m.requeueDelay != minRequeueDelay {
+		t.Errorf("got requeue delay %v after a success and a failure, want %v", m.requeueDelay, minRequeueDelay)
+	}
+}
+
+func TestConsumerManagerRequeueDelay(t *testing.T) {
+	m := NewConsumerManager(conf.RabbitMQConfig{}, "test")
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 30 * time.Second, 30 * time.Second}
+	for i, w := range want {
+		if got := m.nextRequeueDelay(); got != w {
+			t.Errorf("failure %d: got delay %v, want %v", i+1, got, w)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package rabbit_mq
+
+import (
+	"context"
+	"errors"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"google.golang.org/protobuf/proto"
+	"log/slog"
+	"ryg-user-service/gen_proto/email_service"
+	"ryg-user-service/model"
+)
+
+const (
+	EmailDeliveryQueueName    = "user_service.email_delivery_events"
+	emailBouncedRoutingKey    = "email.bounced"
+	emailComplainedRoutingKey = "email.complained"
+)
+
+var errEmptyRecipient = errors.New("delivery event has no email")
+
+// EmailStatusUpdater records that an address can no longer receive emails.
+type EmailStatusUpdater func(ctx context.Context, email, emailStatus string) error
+
+// HandleEmailDeliveryEvents subscribes m to bounce and complaint events from the
+// email service and reports the affected addresses to update.
+func HandleEmailDeliveryEvents(m *ConsumerManager, update EmailStatusUpdater) {
+	m.Handle(exchangeName, emailBouncedRoutingKey, emailDeliveryHandler(model.EmailStatusBounced, update))
+	m.Handle(exchangeName, emailComplainedRoutingKey, emailDeliveryHandler(model.EmailStatusComplained, update))
+}
+
+func emailDeliveryHandler(emailStatus string, update EmailStatusUpdater) Handler {
+	return func(ctx context.Context, delivery amqp.Delivery) error {
+		var event email_service.EmailDeliveryEvent
+		if err := proto.Unmarshal(delivery.Body, &event); err != nil {
+			return Permanent(err)
+		}
+		if event.Email == "" {
+			return Permanent(errEmptyRecipient)
+		}
+
+		slog.InfoContext(ctx, "Received email delivery event", "email_status", emailStatus, "message_id", event.MessageId, "reason", event.Reason)
+		return update(ctx, event.Email, emailStatus)
+	}
+}
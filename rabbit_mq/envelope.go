@@ -0,0 +1,119 @@
+package rabbit_mq
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/protobuf/proto"
+	"ryg-user-service/logging"
+	"time"
+)
+
+const (
+	appID                  = "ryg-user-service"
+	protobufContentType    = "application/protobuf"
+	schemaVersion          = "1"
+	cloudEventsSpecVersion = "1.0"
+	cloudEventsSource      = "/ryg-user-service"
+
+	schemaVersionHeader = "schema-version"
+	requestIDHeader     = logging.RequestIDHeader
+)
+
+type messageIDKey struct{}
+
+// WithMessageID makes the next publish with ctx reuse id instead of generating one,
+// so that retries of the same message can be deduplicated by consumers.
+func WithMessageID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, messageIDKey{}, id)
+}
+
+func NewMessageID() string {
+	return uuid.NewString()
+}
+
+// Envelope is the metadata sent alongside every message body.
+type Envelope struct {
+	MessageID     string
+	Timestamp     time.Time
+	Type          string
+	SchemaVersion string
+	RequestID     string
+	// TraceContext holds the W3C trace context headers of the span in the
+	// publishing context, e.g. traceparent.
+	TraceContext map[string]string
+}
+
+func newEnvelope(ctx context.Context, data proto.Message) Envelope {
+	messageID, _ := ctx.Value(messageIDKey{}).(string)
+	if messageID == "" {
+		messageID = NewMessageID()
+	}
+
+	envelope := Envelope{
+		MessageID:     messageID,
+		Timestamp:     time.Now().UTC(),
+		Type:          string(data.ProtoReflect().Descriptor().FullName()),
+		SchemaVersion: schemaVersion,
+		TraceContext:  map[string]string{},
+	}
+
+	envelope.RequestID = logging.RequestID(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(envelope.TraceContext))
+
+	return envelope
+}
+
+// amqpPublishing sets the AMQP properties and the CloudEvents binary-mode
+// application properties described by the CloudEvents AMQP binding.
+func (e Envelope) amqpPublishing(body []byte) amqp.Publishing {
+	headers := amqp.Table{
+		schemaVersionHeader:       e.SchemaVersion,
+		"cloudEvents:specversion": cloudEventsSpecVersion,
+		"cloudEvents:id":          e.MessageID,
+		"cloudEvents:source":      cloudEventsSource,
+		"cloudEvents:type":        e.Type,
+		"cloudEvents:time":        e.Timestamp.Format(time.RFC3339Nano),
+	}
+	if e.RequestID != "" {
+		headers[requestIDHeader] = e.RequestID
+	}
+	for key, value := range e.TraceContext {
+		headers[key] = value
+	}
+
+	return amqp.Publishing{
+		ContentType: protobufContentType,
+		MessageId:   e.MessageID,
+		Timestamp:   e.Timestamp,
+		Type:        e.Type,
+		AppId:       appID,
+		Headers:     headers,
+		Body:        body,
+	}
+}
+
+// natsMsg builds a JetStream message with the CloudEvents NATS binding headers.
+// Nats-Msg-Id lets JetStream drop duplicates within the stream's window.
+func (e Envelope) natsMsg(subject string, body []byte) *nats.Msg {
+	msg := nats.NewMsg(subject)
+	msg.Header.Set(nats.MsgIdHdr, e.MessageID)
+	msg.Header.Set("Content-Type", protobufContentType)
+	msg.Header.Set(schemaVersionHeader, e.SchemaVersion)
+	msg.Header.Set("ce-specversion", cloudEventsSpecVersion)
+	msg.Header.Set("ce-id", e.MessageID)
+	msg.Header.Set("ce-source", cloudEventsSource)
+	msg.Header.Set("ce-type", e.Type)
+	msg.Header.Set("ce-time", e.Timestamp.Format(time.RFC3339Nano))
+	if e.RequestID != "" {
+		msg.Header.Set(requestIDHeader, e.RequestID)
+	}
+	for key, value := range e.TraceContext {
+		msg.Header.Set(key, value)
+	}
+	msg.Data = body
+	return msg
+}
@@ -1,10 +1,13 @@
 package rabbit_mq
 
 import (
+	"context"
 	amqp "github.com/rabbitmq/amqp091-go"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 	"google.golang.org/protobuf/proto"
-	"log"
 	"ryg-user-service/gen_proto/email_service"
+	"ryg-user-service/metrics"
+	"ryg-user-service/tracing"
 )
 
 const (
@@ -25,24 +28,20 @@ func NewGenericEmailQueuePublisher(ch *amqp.Channel, exchangeName string) *Gener
 	}
 }
 
-func (c *GenericEmailPublisher) Publish(data *email_service.GenericEmail) error {
-	log.Printf("GenericEmailPublisher received message: %v", data)
-
+func (c *GenericEmailPublisher) Publish(ctx context.Context, data *email_service.GenericEmail) error {
 	body, err := proto.Marshal(data)
 	if err != nil {
 		return err
 	}
 
-	err = c.Ch.Publish(
-		c.exchangeName,         // exchange name
-		genericEmailRoutingKey, // routing key (dynamic for topic exchange)
-		false,                  // mandatory
-		false,                  // immediate
-		amqp.Publishing{
-			ContentType: "application/protobuf",
-			Body:        body,
-		},
-	)
+	ctx, span := startPublishSpan(ctx, semconv.MessagingSystemRabbitmq, c.exchangeName, semconv.MessagingRabbitmqDestinationRoutingKey(genericEmailRoutingKey))
+	envelope := newEnvelope(ctx, data)
+	span.SetAttributes(semconv.MessagingMessageID(envelope.MessageID))
+
+	err = c.publishConfirmed(ctx, c.exchangeName, genericEmailRoutingKey, envelope.amqpPublishing(body))
+	metrics.ObservePublish(c.exchangeName+"."+genericEmailRoutingKey, err)
+	logPublish(ctx, c.exchangeName+"."+genericEmailRoutingKey, envelope, err)
+	tracing.End(span, err)
 	if err != nil {
 		return err
 	}
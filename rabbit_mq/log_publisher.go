@@ -0,0 +1,47 @@
+package rabbit_mq
+
+import (
+	"context"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"sync"
+)
+
+// LogSink is where LogPublishers write their lines. Publishers sharing a sink
+// never interleave their lines.
+type LogSink struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func NewLogSink(out io.Writer) *LogSink {
+	return &LogSink{out: out}
+}
+
+func (s *LogSink) writeLine(line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.out.Write(append(line, '\n'))
+	return err
+}
+
+// LogPublisher writes every message as a line of JSON, for local development.
+type LogPublisher[T proto.Message] struct {
+	sink *LogSink
+}
+
+func NewLogPublisher[T proto.Message](sink *LogSink) *LogPublisher[T] {
+	return &LogPublisher[T]{
+		sink: sink,
+	}
+}
+
+func (p *LogPublisher[T]) Publish(_ context.Context, data T) error {
+	line, err := protojson.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return p.sink.writeLine(line)
+}
@@ -0,0 +1,47 @@
+package rabbit_mq
+
+import (
+	"context"
+	"github.com/nats-io/nats.go/jetstream"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"google.golang.org/protobuf/proto"
+	"ryg-user-service/metrics"
+	"ryg-user-service/tracing"
+	"time"
+)
+
+const natsPublishTimeout = 5 * time.Second
+
+// NatsPublisher publishes protobuf messages to a JetStream subject.
+type NatsPublisher[T proto.Message] struct {
+	js      jetstream.JetStream
+	subject string
+}
+
+func NewNatsPublisher[T proto.Message](js jetstream.JetStream, subject string) *NatsPublisher[T] {
+	return &NatsPublisher[T]{
+		js:      js,
+		subject: subject,
+	}
+}
+
+func (p *NatsPublisher[T]) Publish(ctx context.Context, data T) error {
+	body, err := proto.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	ctx, span := startPublishSpan(ctx, messagingSystemNats, p.subject)
+	envelope := newEnvelope(ctx, data)
+	span.SetAttributes(semconv.MessagingMessageID(envelope.MessageID))
+	msg := envelope.natsMsg(p.subject, body)
+
+	ctx, cancel := context.WithTimeout(ctx, natsPublishTimeout)
+	defer cancel()
+
+	_, err = p.js.PublishMsg(ctx, msg)
+	metrics.ObservePublish(p.subject, err)
+	logPublish(ctx, p.subject, envelope, err)
+	tracing.End(span, err)
+	return err
+}
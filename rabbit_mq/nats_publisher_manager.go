@@ -0,0 +1,77 @@
+package rabbit_mq
+
+import (
+	"context"
+	"fmt"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"log/slog"
+	"ryg-user-service/conf"
+	pbe "ryg-user-service/gen_proto/email_service"
+	pbu "ryg-user-service/gen_proto/user_service"
+	"strings"
+	"time"
+)
+
+const natsSetupTimeout = 10 * time.Second
+
+// Subjects mirror the RabbitMQ exchanges and routing keys: <exchange>.<routing key>.
+var (
+	genericEmailSubject = exchangeName + "." + genericEmailRoutingKey
+	userCreatedSubject  = userEventExchangeName + "." + userCreatedRoutingKey
+)
+
+type NatsPublisherManager struct {
+	nc                    *nats.Conn
+	GenericEmailPublisher *NatsPublisher[*pbe.GenericEmail]
+	UserCreatedPublisher  *NatsPublisher[*pbu.User]
+}
+
+func NewNatsPublisherManager(cnf conf.NatsConfig) NatsPublisherManager {
+	nc, err := nats.Connect(cnf.URL, nats.Name("ryg-user-service"))
+	failOnError(err, "Failed to connect to NATS")
+	slog.Info("Connected to NATS")
+
+	js, err := jetstream.New(nc)
+	failOnError(err, "Failed to create a JetStream context")
+
+	ctx, cancel := context.WithTimeout(context.Background(), natsSetupTimeout)
+	defer cancel()
+
+	for _, name := range []string{exchangeName, userEventExchangeName} {
+		_, err = js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+			Name:     streamName(name),
+			Subjects: []string{name + ".>"},
+			Storage:  jetstream.FileStorage,
+		})
+		failOnError(err, "Failed to declare a stream")
+	}
+	slog.Info("Declared JetStream streams")
+
+	return NatsPublisherManager{
+		nc:                    nc,
+		GenericEmailPublisher: NewNatsPublisher[*pbe.GenericEmail](js, genericEmailSubject),
+		UserCreatedPublisher:  NewNatsPublisher[*pbu.User](js, userCreatedSubject),
+	}
+}
+
+func (m *NatsPublisherManager) Check(_ context.Context) error {
+	if !m.nc.IsConnected() {
+		return fmt.Errorf("nats connection is %s", m.nc.Status())
+	}
+	return nil
+}
+
+// Close flushes pending messages, logging rather than failing when NATS is
+// unreachable, and closes the connection either way.
+func (m *NatsPublisherManager) Close() {
+	if err := m.nc.Flush(); err != nil {
+		slog.Warn("Failed to flush NATS connection", "error", err)
+	}
+	m.nc.Close()
+}
+
+// streamName turns an exchange name into a valid stream name, e.g. EMAIL_SERVICE_TOPICS.
+func streamName(exchange string) string {
+	return strings.ToUpper(exchange)
+}
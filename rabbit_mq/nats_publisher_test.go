@@ -0,0 +1,120 @@
+package rabbit_mq
+
+import (
+	"context"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"google.golang.org/protobuf/proto"
+	"ryg-user-service/conf"
+	pbe "ryg-user-service/gen_proto/email_service"
+	"testing"
+	"time"
+)
+
+// runJetStream starts an embedded NATS server with JetStream enabled.
+func runJetStream(t *testing.T) *server.Server {
+	t.Helper()
+
+	s, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	if !s.ReadyForConnections(10 * time.Second) {
+		t.Fatal("NATS server not ready")
+	}
+	t.Cleanup(s.Shutdown)
+	return s
+}
+
+func TestNatsPublisherRoundTrip(t *testing.T) {
+	s := runJetStream(t)
+
+	m := NewNatsPublisherManager(conf.NatsConfig{URL: s.ClientURL()})
+	defer m.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := m.Check(ctx); err != nil {
+		t.Fatalf("Check() failed: %v", err)
+	}
+
+	sent := &pbe.GenericEmail{To: "jo@example.com", Subject: "Welcome", Body: "Hello"}
+	messageID := NewMessageID()
+	// Publishing the same message ID again is a retry and must not be stored twice.
+	for i := 0; i < 2; i++ {
+		if err := m.GenericEmailPublisher.Publish(WithMessageID(ctx, messageID), sent); err != nil {
+			t.Fatalf("Publish() failed: %v", err)
+		}
+	}
+
+	nc, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	js, err := jetstream.New(nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := js.Stream(ctx, streamName(exchangeName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := stream.Info(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.State.Msgs != 1 {
+		t.Errorf("got %d messages in the stream, want 1", info.State.Msgs)
+	}
+
+	msg, err := stream.GetMsg(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Subject != genericEmailSubject {
+		t.Errorf("got subject %q, want %q", msg.Subject, genericEmailSubject)
+	}
+	for header, want := range map[string]string{
+		nats.MsgIdHdr:       messageID,
+		"ce-id":             messageID,
+		"ce-type":           string(sent.ProtoReflect().Descriptor().FullName()),
+		"Content-Type":      protobufContentType,
+		schemaVersionHeader: schemaVersion,
+	} {
+		if got := msg.Header.Get(header); got != want {
+			t.Errorf("got header %s %q, want %q", header, got, want)
+		}
+	}
+
+	received := &pbe.GenericEmail{}
+	if err := proto.Unmarshal(msg.Data, received); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(received, sent) {
+		t.Errorf("got message %v, want %v", received, sent)
+	}
+}
+
+func TestNatsPublisherManagerCloseWithoutServer(t *testing.T) {
+	s := runJetStream(t)
+	m := NewNatsPublisherManager(conf.NatsConfig{URL: s.ClientURL()})
+
+	s.Shutdown()
+	// Close must not panic when the flush fails.
+	m.Close()
+	if !m.nc.IsClosed() {
+		t.Error("connection still open after Close")
+	}
+}
@@ -1,19 +1,85 @@
 package rabbit_mq
 
 import (
+	"context"
+	"errors"
 	ampq "github.com/rabbitmq/amqp091-go"
-	"log"
+	"log/slog"
+	"sync"
+	"time"
 )
 
+// publishConfirmTimeout bounds the wait for a broker confirm when ctx has no
+// earlier deadline.
+const publishConfirmTimeout = 10 * time.Second
+
+var errPublishNacked = errors.New("broker rejected the message")
+
 type Publisher[T any] interface {
-	Publish(data T) error
+	Publish(ctx context.Context, data T) error
 }
 
 type BasePublisher struct {
+	mu sync.RWMutex
 	Ch *ampq.Channel
 }
 
 func (bqc *BasePublisher) Publish(msg string) error {
-	log.Print("Base publisher received message: ", msg)
+	slog.Debug("Base publisher received message", "message", msg)
 	return nil
 }
+
+func (bqc *BasePublisher) channel() *ampq.Channel {
+	bqc.mu.RLock()
+	defer bqc.mu.RUnlock()
+
+	return bqc.Ch
+}
+
+// publishConfirmed publishes msg and waits until the broker confirms it, so a
+// message is only reported as sent once the broker has taken it over. The channel
+// must be in confirm mode.
+func (bqc *BasePublisher) publishConfirmed(ctx context.Context, exchange, routingKey string, msg ampq.Publishing) error {
+	confirmation, err := bqc.channel().PublishWithDeferredConfirmWithContext(
+		ctx,
+		exchange,   // exchange name
+		routingKey, // routing key (dynamic for topic exchange)
+		false,      // mandatory
+		false,      // immediate
+		msg,
+	)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, publishConfirmTimeout)
+	defer cancel()
+
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return errPublishNacked
+	}
+	return nil
+}
+
+// SetChannel switches the publisher to ch, e.g. after a reconnect.
+func (bqc *BasePublisher) SetChannel(ch *ampq.Channel) {
+	bqc.mu.Lock()
+	defer bqc.mu.Unlock()
+
+	bqc.Ch = ch
+}
+
+// logPublish logs the envelope of a message, never its body, which holds email
+// addresses and other user data.
+func logPublish(ctx context.Context, topic string, envelope Envelope, err error) {
+	attrs := []any{"topic", topic, "message_id", envelope.MessageID, "type", envelope.Type}
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to publish message", append(attrs, "error", err)...)
+		return
+	}
+	slog.DebugContext(ctx, "Published message", attrs...)
+}
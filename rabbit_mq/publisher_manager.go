@@ -1,55 +1,189 @@
 package rabbit_mq
 
 import (
+	"context"
+	"errors"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"log"
+	"log/slog"
 	"ryg-user-service/conf"
+	"sync"
 )
 
-const exchangeName = "email_service_topics"
+const (
+	exchangeName          = "email_service_topics"
+	userEventExchangeName = "user_service_topics"
+)
 
+// PublisherManager owns the connection the publishers use. When the broker
+// closes it, e.g. on a restart, the manager reconnects in the background.
 type PublisherManager struct {
+	mu                         sync.Mutex
+	cnf                        conf.RabbitMQConfig
 	conn                       *amqp.Connection
 	ch                         *amqp.Channel
 	GenericEmailQueuePublisher *GenericEmailPublisher
+	UserCreatedPublisher       *UserCreatedPublisher
+
+	stop     chan struct{}
+	stopOnce sync.Once
 }
 
-func NewPublisherManager(cnf conf.RabbitMQConfig) PublisherManager {
-	url := "amqp://" + cnf.User + ":" + cnf.Password + "@" + cnf.Host + ":" + cnf.Port + "/"
-	conn, err := amqp.Dial(url)
+func NewPublisherManager(cnf conf.RabbitMQConfig) *PublisherManager {
+	conn, ch, err := dial(cnf)
 	failOnError(err, "Failed to connect to RabbitMQ")
-	log.Printf("Connected to RabbitMQ")
 
-	ch, err := conn.Channel()
-	failOnError(err, "Failed to open a channel")
-	log.Printf("Opened a channel")
-
-	err = ch.ExchangeDeclare(
-		exchangeName, // exchange name
-		"topic",      // exchange type
-		true,         // durable
-		false,        // auto-deleted
-		false,        // internal
-		false,        // no-wait
-		nil,          // arguments
-	)
-	failOnError(err, "Failed to declare an exchange")
-
-	genericEmailPublisher := NewGenericEmailQueuePublisher(ch, exchangeName)
-
-	return PublisherManager{
+	qcm := &PublisherManager{
+		cnf:                        cnf,
 		conn:                       conn,
 		ch:                         ch,
-		GenericEmailQueuePublisher: genericEmailPublisher,
+		GenericEmailQueuePublisher: NewGenericEmailQueuePublisher(ch, exchangeName),
+		UserCreatedPublisher:       NewUserCreatedPublisher(ch, userEventExchangeName),
+		stop:                       make(chan struct{}),
+	}
+	qcm.watch(conn, ch)
+	return qcm
+}
+
+func amqpURL(cnf conf.RabbitMQConfig) string {
+	return "amqp://" + cnf.User + ":" + cnf.Password + "@" + cnf.Host + ":" + cnf.Port + "/"
+}
+
+func dial(cnf conf.RabbitMQConfig) (*amqp.Connection, *amqp.Channel, error) {
+	conn, err := amqp.Dial(amqpURL(cnf))
+	if err != nil {
+		return nil, nil, err
+	}
+	slog.Info("Connected to RabbitMQ")
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	slog.Info("Opened a channel")
+
+	if err := ch.Confirm(false); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	for _, name := range []string{exchangeName, userEventExchangeName} {
+		err = ch.ExchangeDeclare(
+			name,    // exchange name
+			"topic", // exchange type
+			true,    // durable
+			false,   // auto-deleted
+			false,   // internal
+			false,   // no-wait
+			nil,     // arguments
+		)
+		if err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+	}
+
+	return conn, ch, nil
+}
+
+// watch reconnects when the broker closes conn or ch. Closing them ourselves,
+// in Reconnect or Close, reports no error and ends the watch.
+func (qcm *PublisherManager) watch(conn *amqp.Connection, ch *amqp.Channel) {
+	connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+	chClosed := ch.NotifyClose(make(chan *amqp.Error, 1))
+
+	go func() {
+		var reason *amqp.Error
+		select {
+		case reason = <-connClosed:
+		case reason = <-chClosed:
+		case <-qcm.stop:
+			return
+		}
+		if reason == nil {
+			return
+		}
+
+		slog.Warn("RabbitMQ publisher connection lost, reconnecting", "error", reason)
+		retryUntil(qcm.stop, "RabbitMQ publishers", func() error {
+			qcm.mu.Lock()
+			cnf := qcm.cnf
+			qcm.mu.Unlock()
+			return qcm.Reconnect(cnf)
+		})
+	}()
+}
+
+// Reconnect opens a new connection with cnf, e.g. after the password was rotated,
+// moves the publishers over to it and closes the old one. The old connection is
+// kept when the new one can't be opened, but cnf is used from now on.
+func (qcm *PublisherManager) Reconnect(cnf conf.RabbitMQConfig) error {
+	qcm.mu.Lock()
+	qcm.cnf = cnf
+	qcm.mu.Unlock()
+
+	conn, ch, err := dial(cnf)
+	if err != nil {
+		return err
+	}
+
+	qcm.mu.Lock()
+	select {
+	case <-qcm.stop:
+		// Closed while dialing.
+		qcm.mu.Unlock()
+		closeQuietly(ch, conn)
+		return nil
+	default:
+	}
+	oldConn, oldCh := qcm.conn, qcm.ch
+	qcm.conn, qcm.ch = conn, ch
+	qcm.GenericEmailQueuePublisher.SetChannel(ch)
+	qcm.UserCreatedPublisher.SetChannel(ch)
+	qcm.mu.Unlock()
+	qcm.watch(conn, ch)
+
+	closeQuietly(oldCh, oldConn)
+	slog.Info("Reconnected to RabbitMQ")
+	return nil
+}
+
+// closeQuietly closes ch and conn, which may already have been closed by the
+// broker.
+func closeQuietly(ch *amqp.Channel, conn *amqp.Connection) {
+	if err := ch.Close(); err != nil && !errors.Is(err, amqp.ErrClosed) {
+		slog.Warn("Failed to close channel", "error", err)
+	}
+	if err := conn.Close(); err != nil && !errors.Is(err, amqp.ErrClosed) {
+		slog.Warn("Failed to close connection", "error", err)
+	}
+}
+
+// Check reports an error when the connection or the channel has been closed,
+// e.g. by a broker restart, until the manager has reconnected.
+func (qcm *PublisherManager) Check(_ context.Context) error {
+	qcm.mu.Lock()
+	defer qcm.mu.Unlock()
+
+	if qcm.conn.IsClosed() {
+		return errors.New("rabbitmq connection is closed")
 	}
+	if qcm.ch.IsClosed() {
+		return errors.New("rabbitmq channel is closed")
+	}
+	return nil
 }
 
 func (qcm *PublisherManager) Close() {
-	err := qcm.ch.Close()
-	failOnError(err, "Failed to close channel")
+	qcm.stopOnce.Do(func() {
+		close(qcm.stop)
+	})
+
+	qcm.mu.Lock()
+	defer qcm.mu.Unlock()
 
-	err = qcm.conn.Close()
-	failOnError(err, "Failed to close connection")
+	closeQuietly(qcm.ch, qcm.conn)
 }
 
 func failOnError(err error, msg string) {
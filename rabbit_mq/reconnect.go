@@ -0,0 +1,31 @@
+package rabbit_mq
+
+import (
+	"log/slog"
+	"time"
+)
+
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+// retryUntil calls connect until it succeeds or stop is closed, doubling the delay
+// between attempts up to maxReconnectDelay. It reports whether connect succeeded.
+func retryUntil(stop <-chan struct{}, what string, connect func() error) bool {
+	delay := minReconnectDelay
+	for {
+		err := connect()
+		if err == nil {
+			return true
+		}
+		slog.Error("Failed to reconnect, retrying", "what", what, "delay", delay, "error", err)
+
+		select {
+		case <-stop:
+			return false
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, maxReconnectDelay)
+	}
+}
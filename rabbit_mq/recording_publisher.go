@@ -0,0 +1,53 @@
+package rabbit_mq
+
+import (
+	"context"
+	"sync"
+)
+
+// RecordingPublisher keeps published messages in memory, for tests and for running
+// the service without a broker.
+type RecordingPublisher[T any] struct {
+	// limit is the number of most recent messages kept, all of them when 0.
+	limit int
+
+	mu       sync.Mutex
+	messages []T
+}
+
+// NewRecordingPublisher keeps every message, for tests.
+func NewRecordingPublisher[T any]() *RecordingPublisher[T] {
+	return &RecordingPublisher[T]{}
+}
+
+// NewBoundedRecordingPublisher keeps the last limit messages, so that a long
+// running server doesn't grow without bound.
+func NewBoundedRecordingPublisher[T any](limit int) *RecordingPublisher[T] {
+	return &RecordingPublisher[T]{limit: limit}
+}
+
+func (p *RecordingPublisher[T]) Publish(_ context.Context, data T) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.messages = append(p.messages, data)
+	if p.limit > 0 && len(p.messages) > p.limit {
+		// Copy, so that the dropped messages can be collected.
+		p.messages = append([]T(nil), p.messages[len(p.messages)-p.limit:]...)
+	}
+	return nil
+}
+
+func (p *RecordingPublisher[T]) Messages() []T {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]T(nil), p.messages...)
+}
+
+func (p *RecordingPublisher[T]) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.messages = nil
+}
@@ -0,0 +1,20 @@
+package rabbit_mq
+
+import (
+	"context"
+	"testing"
+)
+
+func TestBoundedRecordingPublisher(t *testing.T) {
+	p := NewBoundedRecordingPublisher[int](3)
+	for i := 1; i <= 5; i++ {
+		if err := p.Publish(context.Background(), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := p.Messages()
+	if len(got) != 3 || got[0] != 3 || got[2] != 5 {
+		t.Errorf("got messages %v, want the last three [3 4 5]", got)
+	}
+}
@@ -0,0 +1,21 @@
+package rabbit_mq
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"ryg-user-service/tracing"
+)
+
+var messagingSystemNats = semconv.MessagingSystemKey.String("nats")
+
+// startPublishSpan starts a producer span; the envelope built from the returned
+// context carries it to consumers.
+func startPublishSpan(ctx context.Context, system attribute.KeyValue, destination string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.Tracer.Start(ctx, destination+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(system, semconv.MessagingDestinationName(destination), semconv.MessagingOperationTypePublish),
+		trace.WithAttributes(attrs...),
+	)
+}
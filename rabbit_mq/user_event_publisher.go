@@ -0,0 +1,46 @@
+package rabbit_mq
+
+import (
+	"context"
+	amqp "github.com/rabbitmq/amqp091-go"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"google.golang.org/protobuf/proto"
+	"ryg-user-service/gen_proto/user_service"
+	"ryg-user-service/metrics"
+	"ryg-user-service/tracing"
+)
+
+const (
+	userCreatedRoutingKey = "user.created"
+)
+
+type UserCreatedPublisher struct {
+	exchangeName string
+	BasePublisher
+}
+
+func NewUserCreatedPublisher(ch *amqp.Channel, exchangeName string) *UserCreatedPublisher {
+	return &UserCreatedPublisher{
+		exchangeName: exchangeName,
+		BasePublisher: BasePublisher{
+			Ch: ch,
+		},
+	}
+}
+
+func (c *UserCreatedPublisher) Publish(ctx context.Context, data *user_service.User) error {
+	body, err := proto.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	ctx, span := startPublishSpan(ctx, semconv.MessagingSystemRabbitmq, c.exchangeName, semconv.MessagingRabbitmqDestinationRoutingKey(userCreatedRoutingKey))
+	envelope := newEnvelope(ctx, data)
+	span.SetAttributes(semconv.MessagingMessageID(envelope.MessageID))
+
+	err = c.publishConfirmed(ctx, c.exchangeName, userCreatedRoutingKey, envelope.amqpPublishing(body))
+	metrics.ObservePublish(c.exchangeName+"."+userCreatedRoutingKey, err)
+	logPublish(ctx, c.exchangeName+"."+userCreatedRoutingKey, envelope, err)
+	tracing.End(span, err)
+	return err
+}
@@ -0,0 +1,53 @@
+package rate_limit
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"google.golang.org/grpc/metadata"
+	"strings"
+)
+
+// forwardedTokenHeader carries the token of the TrustedForwarder that set the
+// x-forwarded-for header.
+const forwardedTokenHeader = "x-forwarded-token"
+
+// TrustedForwarder lets the gateway, which calls the server on behalf of HTTP
+// clients, tell it their addresses. The header is only trusted along with a
+// random token known to this process, so neither clients nor proxies in front of
+// the server, e.g. a sidecar connecting from loopback, can set it.
+type TrustedForwarder struct {
+	token string
+}
+
+func NewTrustedForwarder() *TrustedForwarder {
+	token := make([]byte, 32)
+	// crypto/rand.Read never fails.
+	_, _ = rand.Read(token)
+	return &TrustedForwarder{token: hex.EncodeToString(token)}
+}
+
+// Forward sets the address of the client a call is made for on md.
+func (f *TrustedForwarder) Forward(md metadata.MD, clientIP string) {
+	md.Set(ForwardedForHeader, clientIP)
+	md.Set(forwardedTokenHeader, f.token)
+}
+
+// forwardedFor returns the client address set by Forward, if the call came
+// through it.
+func (f *TrustedForwarder) forwardedFor(ctx context.Context) (string, bool) {
+	if f == nil {
+		return "", false
+	}
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+
+	tokens, addrs := md.Get(forwardedTokenHeader), md.Get(ForwardedForHeader)
+	if len(tokens) != 1 || len(addrs) == 0 || subtle.ConstantTimeCompare([]byte(tokens[0]), []byte(f.token)) != 1 {
+		return "", false
+	}
+	return strings.TrimSpace(addrs[0]), true
+}
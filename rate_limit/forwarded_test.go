@@ -0,0 +1,39 @@
+package rate_limit
+
+import (
+	"context"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"net"
+	"testing"
+)
+
+func TestTrustedForwarder(t *testing.T) {
+	f := NewTrustedForwarder()
+	loopback := &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5000}}
+
+	forwarded := metadata.MD{}
+	f.Forward(forwarded, "203.0.113.7")
+
+	tests := []struct {
+		name      string
+		forwarder *TrustedForwarder
+		md        metadata.MD
+		want      string
+	}{
+		{"forwarded", f, forwarded, "203.0.113.7"},
+		{"no header", f, metadata.MD{}, "127.0.0.1"},
+		{"header without token", f, metadata.Pairs(ForwardedForHeader, "203.0.113.7"), "127.0.0.1"},
+		{"header with wrong token", f, metadata.Pairs(ForwardedForHeader, "203.0.113.7", forwardedTokenHeader, "guess"), "127.0.0.1"},
+		{"token of another forwarder", NewTrustedForwarder(), forwarded, "127.0.0.1"},
+		{"no forwarder", nil, forwarded, "127.0.0.1"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := peer.NewContext(metadata.NewIncomingContext(context.Background(), tc.md), loopback)
+			if got := peerIP(ctx, tc.forwarder); got != tc.want {
+				t.Errorf("peerIP() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package rate_limit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"log/slog"
+	"math"
+	"net"
+	"ryg-user-service/conf"
+	"ryg-user-service/tls_config"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// RetryAfterHeader holds the number of seconds to wait, like the HTTP header.
+	RetryAfterHeader = "retry-after"
+	// ForwardedForHeader is trusted from the TrustedForwarder of the gateway only.
+	ForwardedForHeader = "x-forwarded-for"
+	apiKeyHeader       = "x-api-key"
+)
+
+// UnaryServerInterceptor applies the rule of each method. The limiter failing
+// lets requests through: a database outage shouldn't block signups on its own.
+// Calls forwarded by forwarder are keyed by the address of their HTTP client.
+func UnaryServerInterceptor(limiter Limiter, cnf conf.RateLimitConfig, forwarder *TrustedForwarder) grpc.UnaryServerInterceptor {
+	byMethod := map[string]conf.RateLimitRule{}
+	for _, rule := range cnf.Rules {
+		byMethod[rule.Method] = rule
+	}
+	apiKeys := map[string]bool{}
+	for _, digest := range cnf.APIKeyDigests {
+		apiKeys[strings.ToLower(digest)] = true
+	}
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		rule, ok := byMethod[info.FullMethod]
+		if !ok {
+			rule, ok = byMethod["*"]
+		}
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		key := info.FullMethod + "|" + clientKey(ctx, rule.Key, apiKeys, forwarder)
+		allowed, retryAfter, err := limiter.Allow(ctx, key, rule)
+		if err != nil {
+			slog.ErrorContext(ctx, "Rate limiter failed, allowing request", "method", info.FullMethod, "error", err)
+			return handler(ctx, req)
+		}
+		if !allowed {
+			return nil, exhausted(ctx, retryAfter)
+		}
+		return handler(ctx, req)
+	}
+}
+
+func exhausted(ctx context.Context, retryAfter time.Duration) error {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RetryAfterHeader, strconv.Itoa(seconds)))
+
+	st := status.New(codes.ResourceExhausted, fmt.Sprintf("rate limit exceeded, retry in %ds", seconds))
+	if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// clientKey identifies the caller by kind, falling back to the IP address when
+// the caller has no principal or an API key missing from apiKeys, the set of
+// known key digests. Calls of the gateway never have a principal, see
+// tls_config.ClientIdentity.
+func clientKey(ctx context.Context, kind string, apiKeys map[string]bool, forwarder *TrustedForwarder) string {
+	switch kind {
+	case conf.RateLimitKeyPrincipal:
+		if identity, ok := tls_config.ClientIdentity(ctx); ok {
+			return "principal:" + identity.Name()
+		}
+	case conf.RateLimitKeyAPIKey:
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(apiKeyHeader); len(values) > 0 && values[0] != "" {
+				// Only a digest of the key is kept, in memory or in the database.
+				sum := sha256.Sum256([]byte(values[0]))
+				if digest := hex.EncodeToString(sum[:]); apiKeys[digest] {
+					return "api_key:" + digest
+				}
+			}
+		}
+	}
+	return "peer:" + peerIP(ctx, forwarder)
+}
+
+func peerIP(ctx context.Context, forwarder *TrustedForwarder) string {
+	if ip, ok := forwarder.forwardedFor(ctx); ok {
+		return ip
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "unknown"
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+	return host
+}
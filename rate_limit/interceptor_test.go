@@ -0,0 +1,35 @@
+package rate_limit
+
+import (
+	"context"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"net"
+	"ryg-user-service/conf"
+	"testing"
+)
+
+func TestClientKeyAPIKey(t *testing.T) {
+	// The SHA-256 digest of "known-key".
+	const digest = "407161b84e7588cb559868118627fd5c4d57e328623c74474f303dc8667cbda1"
+	apiKeys := map[string]bool{digest: true}
+	client := &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(203, 0, 113, 7), Port: 5000}}
+
+	tests := []struct {
+		name string
+		md   metadata.MD
+		want string
+	}{
+		{"known key", metadata.Pairs(apiKeyHeader, "known-key"), "api_key:" + digest},
+		{"unknown key", metadata.Pairs(apiKeyHeader, "made-up-key"), "peer:203.0.113.7"},
+		{"no key", metadata.MD{}, "peer:203.0.113.7"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := peer.NewContext(metadata.NewIncomingContext(context.Background(), tc.md), client)
+			if got := clientKey(ctx, conf.RateLimitKeyAPIKey, apiKeys, nil); got != tc.want {
+				t.Errorf("clientKey() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package rate_limit
+
+import (
+	"context"
+	"math"
+	"ryg-user-service/conf"
+	"time"
+)
+
+// Limiter takes a token from the bucket of key, which is refilled as rule says.
+// retryAfter is how long until a token is available when ok is false.
+type Limiter interface {
+	Allow(ctx context.Context, key string, rule conf.RateLimitRule) (ok bool, retryAfter time.Duration, err error)
+}
+
+// refill returns the tokens in a bucket that had tokens elapsed ago.
+func refill(tokens float64, elapsed time.Duration, rule conf.RateLimitRule) float64 {
+	return math.Min(float64(rule.Burst), tokens+elapsed.Seconds()*rule.Rate)
+}
+
+// waitFor returns how long a bucket with tokens takes to hold one.
+func waitFor(tokens float64, rule conf.RateLimitRule) time.Duration {
+	return time.Duration((1 - tokens) / rule.Rate * float64(time.Second))
+}
+
+// idleAfter is how long any bucket takes to fill up again, after which it's the
+// same as no bucket at all and can be dropped.
+func idleAfter(rules []conf.RateLimitRule) time.Duration {
+	var longest time.Duration
+	for _, rule := range rules {
+		if d := time.Duration(float64(rule.Burst) / rule.Rate * float64(time.Second)); d > longest {
+			longest = d
+		}
+	}
+	return longest
+}
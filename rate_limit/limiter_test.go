@@ -0,0 +1,56 @@
+package rate_limit
+
+import (
+	"ryg-user-service/conf"
+	"testing"
+	"time"
+)
+
+func TestRefill(t *testing.T) {
+	rule := conf.RateLimitRule{Rate: 2, Burst: 5}
+
+	tests := []struct {
+		tokens  float64
+		elapsed time.Duration
+		want    float64
+	}{
+		{0, 0, 0},
+		{0, 500 * time.Millisecond, 1},
+		{1.5, time.Second, 3.5},
+		{4, time.Second, 5},
+		{0, time.Hour, 5},
+	}
+	for _, tc := range tests {
+		if got := refill(tc.tokens, tc.elapsed, rule); got != tc.want {
+			t.Errorf("refill(%v, %v) = %v, want %v", tc.tokens, tc.elapsed, got, tc.want)
+		}
+	}
+}
+
+func TestWaitFor(t *testing.T) {
+	rule := conf.RateLimitRule{Rate: 4, Burst: 1}
+
+	tests := []struct {
+		tokens float64
+		want   time.Duration
+	}{
+		{0, 250 * time.Millisecond},
+		{0.5, 125 * time.Millisecond},
+		{1, 0},
+	}
+	for _, tc := range tests {
+		if got := waitFor(tc.tokens, rule); got != tc.want {
+			t.Errorf("waitFor(%v) = %v, want %v", tc.tokens, got, tc.want)
+		}
+	}
+}
+
+func TestIdleAfter(t *testing.T) {
+	rules := []conf.RateLimitRule{
+		{Rate: 10, Burst: 5},
+		{Rate: 0.5, Burst: 2},
+	}
+	if got, want := idleAfter(rules), 4*time.Second; got != want {
+		t.Errorf("idleAfter() = %v, want %v", got, want)
+	}
+}
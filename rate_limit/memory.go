@@ -0,0 +1,68 @@
+package rate_limit
+
+import (
+	"context"
+	"ryg-user-service/conf"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// MemoryLimiter keeps the buckets in this process, so every replica allows the
+// configured rate on its own.
+type MemoryLimiter struct {
+	idleAfter time.Duration
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemoryLimiter(rules []conf.RateLimitRule) *MemoryLimiter {
+	return &MemoryLimiter{
+		idleAfter: idleAfter(rules),
+		buckets:   map[string]*bucket{},
+		lastSweep: time.Now(),
+	}
+}
+
+func (l *MemoryLimiter) Allow(_ context.Context, key string, rule conf.RateLimitRule) (bool, time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rule.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = refill(b.tokens, now.Sub(b.last), rule)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, waitFor(b.tokens, rule), nil
+	}
+	b.tokens--
+	return true, 0, nil
+}
+
+// sweep drops the buckets that are full again, so that one-off clients don't
+// accumulate.
+func (l *MemoryLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.idleAfter {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= l.idleAfter {
+			delete(l.buckets, key)
+		}
+	}
+}
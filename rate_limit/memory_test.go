@@ -0,0 +1,73 @@
+package rate_limit
+
+import (
+	"context"
+	"ryg-user-service/conf"
+	"testing"
+	"time"
+)
+
+func TestMemoryLimiterAllow(t *testing.T) {
+	// Slow enough that no token is refilled while the test runs.
+	rule := conf.RateLimitRule{Method: "*", Key: conf.RateLimitKeyPeer, Rate: 0.001, Burst: 3}
+	l := NewMemoryLimiter([]conf.RateLimitRule{rule})
+	ctx := context.Background()
+
+	for i := 0; i < rule.Burst; i++ {
+		if ok, _, err := l.Allow(ctx, "a", rule); err != nil || !ok {
+			t.Fatalf("call %d: Allow() = %t, %v, want allowed within the burst", i, ok, err)
+		}
+	}
+
+	ok, retryAfter, err := l.Allow(ctx, "a", rule)
+	if err != nil || ok {
+		t.Fatalf("Allow() = %t, %v, want denied after the burst", ok, err)
+	}
+	if longest := waitFor(0, rule); retryAfter <= 0 || retryAfter > longest {
+		t.Errorf("got retryAfter %v, want at most %v", retryAfter, longest)
+	}
+
+	if ok, _, err := l.Allow(ctx, "b", rule); err != nil || !ok {
+		t.Errorf("Allow() for another key = %t, %v, want allowed", ok, err)
+	}
+}
+
+func TestMemoryLimiterRefills(t *testing.T) {
+	rule := conf.RateLimitRule{Method: "*", Key: conf.RateLimitKeyPeer, Rate: 100, Burst: 1}
+	l := NewMemoryLimiter([]conf.RateLimitRule{rule})
+	ctx := context.Background()
+
+	if ok, _, _ := l.Allow(ctx, "a", rule); !ok {
+		t.Fatal("first call denied")
+	}
+	ok, retryAfter, _ := l.Allow(ctx, "a", rule)
+	if ok {
+		// The bucket refilled between the calls already.
+		return
+	}
+
+	time.Sleep(retryAfter)
+	if ok, _, err := l.Allow(ctx, "a", rule); err != nil || !ok {
+		t.Errorf("Allow() after %v = %t, %v, want allowed", retryAfter, ok, err)
+	}
+}
+
+func TestMemoryLimiterSweep(t *testing.T) {
+	rule := conf.RateLimitRule{Method: "*", Key: conf.RateLimitKeyPeer, Rate: 1, Burst: 1}
+	l := NewMemoryLimiter([]conf.RateLimitRule{rule})
+	ctx := context.Background()
+
+	if ok, _, _ := l.Allow(ctx, "a", rule); !ok {
+		t.Fatal("first call denied")
+	}
+
+	now := time.Now()
+	l.sweep(now.Add(l.idleAfter / 2))
+	if _, ok := l.buckets["a"]; !ok {
+		t.Fatal("bucket dropped before it filled up again")
+	}
+	l.sweep(now.Add(2 * l.idleAfter))
+	if _, ok := l.buckets["a"]; ok {
+		t.Error("bucket kept after it filled up again")
+	}
+}
@@ -0,0 +1,95 @@
+package rate_limit
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"log/slog"
+	"ryg-user-service/conf"
+	"sync"
+	"time"
+)
+
+const sweepInterval = 10 * time.Minute
+
+// takeToken refills the bucket for the time since its last update and takes a
+// token if there is one, in a single statement so that concurrent replicas
+// never hand out the same token.
+const takeToken = `
+INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at)
+VALUES (@key, CAST(@burst AS double precision) - 1, true, now())
+ON CONFLICT (key) DO UPDATE SET
+	tokens = LEAST(CAST(@burst AS double precision), b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at) * CAST(@rate AS double precision))
+		- CASE WHEN LEAST(CAST(@burst AS double precision), b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at) * CAST(@rate AS double precision)) >= 1 THEN 1 ELSE 0 END,
+	allowed = LEAST(CAST(@burst AS double precision), b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at) * CAST(@rate AS double precision)) >= 1,
+	updated_at = now()
+RETURNING tokens, allowed`
+
+// PostgresLimiter keeps the buckets in the database, so the configured rate
+// applies to all replicas together.
+type PostgresLimiter struct {
+	db        *gorm.DB
+	idleAfter time.Duration
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewPostgresLimiter(db *gorm.DB, rules []conf.RateLimitRule) *PostgresLimiter {
+	return &PostgresLimiter{
+		db:        db,
+		idleAfter: idleAfter(rules),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+func (l *PostgresLimiter) Allow(ctx context.Context, key string, rule conf.RateLimitRule) (bool, time.Duration, error) {
+	var result struct {
+		Tokens  float64
+		Allowed bool
+	}
+	err := l.db.WithContext(ctx).Raw(takeToken, map[string]any{
+		"key":   key,
+		"burst": float64(rule.Burst),
+		"rate":  rule.Rate,
+	}).Scan(&result).Error
+	if err != nil {
+		return false, 0, err
+	}
+
+	if !result.Allowed {
+		return false, waitFor(result.Tokens, rule), nil
+	}
+	return true, 0, nil
+}
+
+// Start deletes buckets that are full again every sweepInterval.
+func (l *PostgresLimiter) Start() {
+	go func() {
+		defer close(l.done)
+
+		ticker := time.NewTicker(sweepInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-l.stop:
+				return
+			case <-ticker.C:
+			}
+
+			err := l.db.Exec("DELETE FROM rate_limit_buckets WHERE updated_at < ?", time.Now().Add(-l.idleAfter)).Error
+			if err != nil {
+				slog.Warn("Failed to delete idle rate limit buckets", "error", err)
+			}
+		}
+	}()
+}
+
+func (l *PostgresLimiter) Stop() {
+	l.stopOnce.Do(func() {
+		close(l.stop)
+		<-l.done
+	})
+}
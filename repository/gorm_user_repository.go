@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"ryg-user-service/model"
+)
+
+const uniqueViolationCode = "23505"
+
+type GormUserRepository struct {
+	db *gorm.DB
+}
+
+func NewGormUserRepository(db *gorm.DB) *GormUserRepository {
+	return &GormUserRepository{
+		db: db,
+	}
+}
+
+func (r *GormUserRepository) GetByID(ctx context.Context, id int64) (*model.User, error) {
+	var user model.User
+	if err := r.db.WithContext(ctx).First(&user, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *GormUserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	var user model.User
+	if err := r.db.WithContext(ctx).Where("lower(email) = lower(?)", email).First(&user).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *GormUserRepository) List(ctx context.Context, limit, offset int) ([]model.User, error) {
+	var users []model.User
+	if err := r.db.WithContext(ctx).Order("id").Limit(limit).Offset(offset).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *GormUserRepository) Create(ctx context.Context, user *model.User) error {
+	return translateError(r.db.WithContext(ctx).Create(user).Error)
+}
+
+func (r *GormUserRepository) Update(ctx context.Context, user *model.User) error {
+	result := r.db.WithContext(ctx).Select("*").Updates(user)
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *GormUserRepository) SetEmail(ctx context.Context, id int64, email string) error {
+	result := r.db.WithContext(ctx).
+		Model(&model.User{}).
+		Where("id = ?", id).
+		Update("email", email)
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *GormUserRepository) SetEmailStatus(ctx context.Context, email, emailStatus string) error {
+	return r.db.WithContext(ctx).
+		Model(&model.User{}).
+		Where("lower(email) = lower(?)", email).
+		Update("email_status", emailStatus).Error
+}
+
+func (r *GormUserRepository) EmailSuppressed(ctx context.Context, email string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&model.User{}).
+		Where("lower(email) = lower(?) AND email_status <> ?", email, model.EmailStatusDeliverable).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *GormUserRepository) ReplacePassword(ctx context.Context, id int64, oldHash, newHash string) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&model.User{}).
+		Where("id = ? AND password = ?", id, oldHash).
+		Update("password", newHash)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *GormUserRepository) Delete(ctx context.Context, id int64) error {
+	return r.db.WithContext(ctx).Delete(&model.User{}, id).Error
+}
+
+func translateError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrUserNotFound
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+		return ErrEmailTaken
+	}
+	return err
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"ryg-user-service/model"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MemoryUserRepository keeps users in memory. It applies the same column defaults
+// and email uniqueness as the users table, so the service behaves the same on it.
+type MemoryUserRepository struct {
+	mu     sync.RWMutex
+	users  map[int64]model.User
+	nextID int64
+}
+
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{
+		users:  map[int64]model.User{},
+		nextID: 1,
+	}
+}
+
+func (r *MemoryUserRepository) GetByID(_ context.Context, id int64) (*model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return &user, nil
+}
+
+func (r *MemoryUserRepository) GetByEmail(_ context.Context, email string) (*model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if strings.EqualFold(user.Email, email) {
+			return &user, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+func (r *MemoryUserRepository) List(_ context.Context, limit, offset int) ([]model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := make([]model.User, 0, len(r.users))
+	for _, user := range r.users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+
+	if offset >= len(users) {
+		return []model.User{}, nil
+	}
+	users = users[offset:]
+	if limit >= 0 && limit < len(users) {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+func (r *MemoryUserRepository) Create(_ context.Context, user *model.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.emailTakenLocked(user.Email, 0) {
+		return ErrEmailTaken
+	}
+
+	// Zero values fall back to the column defaults, as gorm does on insert.
+	user.ID = r.nextID
+	user.IsActive = true
+	if user.Locale == "" {
+		user.Locale = "en"
+	}
+	if user.Timezone == "" {
+		user.Timezone = "UTC"
+	}
+	if user.EmailStatus == "" {
+		user.EmailStatus = model.EmailStatusDeliverable
+	}
+
+	r.users[user.ID] = *user
+	r.nextID++
+	return nil
+}
+
+func (r *MemoryUserRepository) Update(_ context.Context, user *model.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[user.ID]; !ok {
+		return ErrUserNotFound
+	}
+	if r.emailTakenLocked(user.Email, user.ID) {
+		return ErrEmailTaken
+	}
+
+	r.users[user.ID] = *user
+	return nil
+}
+
+func (r *MemoryUserRepository) SetEmail(_ context.Context, id int64, email string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return ErrUserNotFound
+	}
+	if r.emailTakenLocked(email, id) {
+		return ErrEmailTaken
+	}
+	user.Email = email
+	r.users[id] = user
+	return nil
+}
+
+func (r *MemoryUserRepository) SetEmailStatus(_ context.Context, email, emailStatus string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, user := range r.users {
+		if strings.EqualFold(user.Email, email) {
+			user.EmailStatus = emailStatus
+			r.users[id] = user
+		}
+	}
+	return nil
+}
+
+func (r *MemoryUserRepository) EmailSuppressed(_ context.Context, email string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.users {
+		if strings.EqualFold(user.Email, email) && !user.EmailDeliverable() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *MemoryUserRepository) ReplacePassword(_ context.Context, id int64, oldHash, newHash string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok || user.Password != oldHash {
+		return false, nil
+	}
+	user.Password = newHash
+	r.users[id] = user
+	return true, nil
+}
+
+func (r *MemoryUserRepository) Delete(_ context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.users, id)
+	return nil
+}
+
+func (r *MemoryUserRepository) emailTakenLocked(email string, exceptID int64) bool {
+	for id, user := range r.users {
+		if id != exceptID && strings.EqualFold(user.Email, email) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"ryg-user-service/model"
+)
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrEmailTaken   = errors.New("email is already taken")
+)
+
+// UserRepository stores users. Implementations return ErrUserNotFound for missing
+// users and ErrEmailTaken when an email is already used by another user. Emails
+// are compared ignoring case.
+type UserRepository interface {
+	GetByID(ctx context.Context, id int64) (*model.User, error)
+	GetByEmail(ctx context.Context, email string) (*model.User, error)
+	List(ctx context.Context, limit, offset int) ([]model.User, error)
+	Create(ctx context.Context, user *model.User) error
+	Update(ctx context.Context, user *model.User) error
+	// SetEmail changes only the email of user id, leaving the other columns to
+	// concurrent writers.
+	SetEmail(ctx context.Context, id int64, email string) error
+	SetEmailStatus(ctx context.Context, email, emailStatus string) error
+	// EmailSuppressed reports whether email belongs to a user whose address bounced
+	// or complained, so that only critical emails may be sent to it.
+	EmailSuppressed(ctx context.Context, email string) (bool, error)
+	// ReplacePassword sets the password hash of user id to newHash only if it is
+	// still oldHash, so that a rehash never undoes a concurrent password change,
+	// and reports whether it did.
+	ReplacePassword(ctx context.Context, id int64, oldHash, newHash string) (bool, error)
+	Delete(ctx context.Context, id int64) error
+}
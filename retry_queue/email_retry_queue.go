@@ -0,0 +1,286 @@
+package retry_queue
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log/slog"
+	"ryg-user-service/conf"
+	pbe "ryg-user-service/gen_proto/email_service"
+	"ryg-user-service/model"
+	"ryg-user-service/rabbit_mq"
+	"sync"
+	"time"
+)
+
+const (
+	// claimLease is how long a claimed email is hidden from other replicas while
+	// it is published; it must exceed the publish timeout.
+	claimLease = time.Minute
+	// enqueueTimeout bounds storing an email once the RPC that sent it returned.
+	enqueueTimeout = 5 * time.Second
+)
+
+var (
+	ErrDeadLetterNotFound  = errors.New("dead letter email not found")
+	ErrRecipientSuppressed = errors.New("recipient address bounced or complained")
+)
+
+// SuppressionCheck reports whether non-critical emails to an address must not be
+// sent, e.g. because it bounced.
+type SuppressionCheck func(ctx context.Context, to string) (bool, error)
+
+// EmailRetryQueue keeps emails that failed to publish in Postgres and retries them
+// with exponential backoff until they are published or run out of attempts.
+type EmailRetryQueue struct {
+	db         *gorm.DB
+	publisher  rabbit_mq.Publisher[*pbe.GenericEmail]
+	suppressed SuppressionCheck
+	cnf        conf.EmailRetryConfig
+	wakeup     chan struct{}
+	stop       chan struct{}
+	done       chan struct{}
+	stopOnce   sync.Once
+}
+
+func NewEmailRetryQueue(db *gorm.DB, publisher rabbit_mq.Publisher[*pbe.GenericEmail], suppressed SuppressionCheck, cnf conf.EmailRetryConfig) *EmailRetryQueue {
+	return &EmailRetryQueue{
+		db:         db,
+		publisher:  publisher,
+		suppressed: suppressed,
+		cnf:        cnf,
+		wakeup:     make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// Enqueue stores an email whose first publish attempt failed with publishErr.
+// Retries are published with the same messageID. Non-critical emails are dropped
+// instead of retried once the recipient address bounced or complained.
+func (q *EmailRetryQueue) Enqueue(ctx context.Context, messageID string, email *pbe.GenericEmail, critical bool, publishErr error) error {
+	pending := &model.PendingEmail{
+		MessageID:     messageID,
+		To:            email.To,
+		Subject:       email.Subject,
+		Body:          email.Body,
+		Critical:      critical,
+		Attempts:      1,
+		LastError:     publishErr.Error(),
+		NextAttemptAt: time.Now().Add(q.backoff(1)),
+	}
+
+	// The email must be kept even when the client gives up on the RPC that sent it.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), enqueueTimeout)
+	defer cancel()
+
+	return q.db.WithContext(ctx).Create(pending).Error
+}
+
+func (q *EmailRetryQueue) Start() {
+	go q.run()
+}
+
+// Stop waits for the batch in progress to finish and stops the worker.
+func (q *EmailRetryQueue) Stop() {
+	q.stopOnce.Do(func() {
+		close(q.stop)
+		<-q.done
+	})
+}
+
+func (q *EmailRetryQueue) run() {
+	defer close(q.done)
+
+	ticker := time.NewTicker(q.cnf.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-ticker.C:
+		case <-q.wakeup:
+		}
+
+		if err := q.processDue(); err != nil {
+			slog.Error("Failed to process pending emails", "error", err)
+		}
+	}
+}
+
+// processDue retries every email whose next attempt is due. The due rows are
+// claimed in a short transaction, locked with SKIP LOCKED so that several replicas
+// can share the queue, by moving their next attempt claimLease ahead. They are
+// published outside of it, so a slow broker never holds row locks, and an email
+// claimed by a replica that died is retried once the lease expires, as is one
+// that failed to be retried.
+func (q *EmailRetryQueue) processDue() error {
+	due, err := q.claimDue()
+	if err != nil {
+		return err
+	}
+
+	for i := range due {
+		if err := q.retry(&due[i]); err != nil {
+			slog.Error("Failed to retry email", "email_id", due[i].ID, "error", err)
+		}
+	}
+	return nil
+}
+
+func (q *EmailRetryQueue) claimDue() ([]model.PendingEmail, error) {
+	var due []model.PendingEmail
+	err := q.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("next_attempt_at <= ?", now).
+			Order("next_attempt_at").
+			Limit(q.cnf.BatchSize).
+			Find(&due).Error
+		if err != nil || len(due) == 0 {
+			return err
+		}
+
+		ids := make([]int64, 0, len(due))
+		for _, pending := range due {
+			ids = append(ids, pending.ID)
+		}
+		return tx.Model(&model.PendingEmail{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(claimLease)).Error
+	})
+	return due, err
+}
+
+func (q *EmailRetryQueue) retry(pending *model.PendingEmail) error {
+	ctx := context.Background()
+	if !pending.Critical {
+		suppressed, err := q.suppressed(ctx, pending.To)
+		if err != nil {
+			return err
+		}
+		if suppressed {
+			slog.Info("Dropping email, its recipient bounced or complained", "email_id", pending.ID)
+			return q.db.Delete(pending).Error
+		}
+	}
+
+	publishErr := q.publisher.Publish(rabbit_mq.WithMessageID(ctx, pending.MessageID), &pbe.GenericEmail{
+		To:      pending.To,
+		Subject: pending.Subject,
+		Body:    pending.Body,
+	})
+	if publishErr == nil {
+		return q.db.Delete(pending).Error
+	}
+
+	pending.Attempts++
+	pending.LastError = publishErr.Error()
+
+	if pending.Attempts >= q.cnf.MaxAttempts {
+		slog.Error("Email exhausted its attempts, moving to dead letters", "email_id", pending.ID, "attempts", pending.Attempts, "error", publishErr)
+		return q.db.Transaction(func(tx *gorm.DB) error {
+			deadLetter := &model.DeadLetterEmail{
+				MessageID: pending.MessageID,
+				To:        pending.To,
+				Subject:   pending.Subject,
+				Body:      pending.Body,
+				Critical:  pending.Critical,
+				Attempts:  pending.Attempts,
+				LastError: pending.LastError,
+				CreatedAt: pending.CreatedAt,
+				FailedAt:  time.Now(),
+			}
+			if err := tx.Create(deadLetter).Error; err != nil {
+				return err
+			}
+			return tx.Delete(pending).Error
+		})
+	}
+
+	pending.NextAttemptAt = time.Now().Add(q.backoff(pending.Attempts))
+	return q.db.Save(pending).Error
+}
+
+// backoff returns the delay before the next attempt after the given number of attempts.
+func (q *EmailRetryQueue) backoff(attempts int) time.Duration {
+	delay := q.cnf.InitialBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= q.cnf.MaxBackoff {
+			return q.cnf.MaxBackoff
+		}
+	}
+	return delay
+}
+
+func (q *EmailRetryQueue) ListDeadLetters(ctx context.Context, limit, offset int) ([]model.DeadLetterEmail, error) {
+	var deadLetters []model.DeadLetterEmail
+	err := q.db.WithContext(ctx).
+		Order("failed_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&deadLetters).Error
+	return deadLetters, err
+}
+
+// RetryDeadLetter moves a dead letter back to the queue with a fresh attempt budget.
+// It returns ErrRecipientSuppressed for a non-critical email whose recipient
+// bounced or complained in the meantime.
+func (q *EmailRetryQueue) RetryDeadLetter(ctx context.Context, id int64) error {
+	err := q.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var deadLetter model.DeadLetterEmail
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&deadLetter, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrDeadLetterNotFound
+			}
+			return err
+		}
+
+		if !deadLetter.Critical {
+			suppressed, err := q.suppressed(ctx, deadLetter.To)
+			if err != nil {
+				return err
+			}
+			if suppressed {
+				return ErrRecipientSuppressed
+			}
+		}
+
+		pending := &model.PendingEmail{
+			MessageID:     deadLetter.MessageID,
+			To:            deadLetter.To,
+			Subject:       deadLetter.Subject,
+			Body:          deadLetter.Body,
+			Critical:      deadLetter.Critical,
+			LastError:     deadLetter.LastError,
+			NextAttemptAt: time.Now(),
+		}
+		if err := tx.Create(pending).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&deadLetter).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case q.wakeup <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (q *EmailRetryQueue) DiscardDeadLetter(ctx context.Context, id int64) error {
+	result := q.db.WithContext(ctx).Delete(&model.DeadLetterEmail{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrDeadLetterNotFound
+	}
+	return nil
+}
@@ -1,8 +1,62 @@
 #!/bin/bash
+set -e
 
-USER_PROTO_DIR="./ryg-protos/user_service"
-EMAIL_PROTO_DIR="./ryg-protos/email_service"
+PROTOS_DIR="./ryg-protos"
+PATCHES_DIR="./scripts/ryg-protos"
+USER_PROTO_DIR="$PROTOS_DIR/user_service"
+EMAIL_PROTO_DIR="$PROTOS_DIR/email_service"
 OUT_DIR="."
+
+# Proto changes that haven't landed in ryg-protos yet are applied to the checkout
+# for the generation and reverted afterwards. The patches land in order, so the
+# longest leading run of them that reverts cleanly is already in ryg-protos; the
+# rest must apply, or the generation stops.
+patches=()
+for patch in "$PATCHES_DIR"/*.patch; do
+  [ -e "$patch" ] && patches+=("$(realpath "$patch")")
+done
+
+# reverts_cleanly N reports whether the first N patches revert on a copy of the
+# checkout.
+reverts_cleanly() {
+  local scratch
+  scratch="$(mktemp -d)"
+  cp -r "$PROTOS_DIR"/. "$scratch"
+  for ((i = $1 - 1; i >= 0; i--)); do
+    if ! (cd "$scratch" && git apply --reverse "${patches[$i]}" 2>/dev/null); then
+      rm -rf "$scratch"
+      return 1
+    fi
+  done
+  rm -rf "$scratch"
+}
+
+landed=${#patches[@]}
+while ((landed > 0)) && ! reverts_cleanly "$landed"; do
+  landed=$((landed - 1))
+done
+
+applied=0
+revert_patches() {
+  for ((i = landed + applied - 1; i >= landed; i--)); do
+    git -C "$PROTOS_DIR" apply --reverse "${patches[$i]}"
+  done
+}
+trap revert_patches EXIT
+
+for ((i = 0; i < ${#patches[@]}; i++)); do
+  name="$(basename "${patches[$i]}")"
+  if ((i < landed)); then
+    echo "Skipped $name, already in ryg-protos"
+  elif git -C "$PROTOS_DIR" apply "${patches[$i]}"; then
+    applied=$((applied + 1))
+    echo "Applied $name"
+  else
+    echo "$name doesn't apply to ryg-protos, update or delete it" >&2
+    exit 1
+  fi
+done
+
 rm -rf "./gen_proto"
 mkdir -p "$OUT_DIR"
 
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	pbu "ryg-user-service/gen_proto/user_service"
+	"ryg-user-service/locale"
+	"ryg-user-service/model"
+	"ryg-user-service/password_hash"
+	"ryg-user-service/repository"
+	"strings"
+)
+
+// Operations below are not exposed over gRPC; they back the admin subcommands of
+// the service binary.
+
+func (s *UserService) CreateAdmin(ctx context.Context, req *pbu.CreateUserRequest) (*pbu.User, error) {
+	return s.createUser(ctx, req, model.RoleAdmin)
+}
+
+func (s *UserService) GetUserByEmail(ctx context.Context, email string) (*pbu.User, error) {
+	email = s.emails.Normalize(email)
+	var v violations
+	v.checkEmail("email", email)
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	user, err := s.users.GetByEmail(ctx, email)
+	if err != nil {
+		return nil, toStatus(ctx, err, "retrieve user")
+	}
+
+	return toUserProto(user), nil
+}
+
+func (s *UserService) SetRole(ctx context.Context, id int64, role string) (*pbu.User, error) {
+	var v violations
+	v.checkRole("role", role)
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	return s.modifyUser(ctx, id, func(user *model.User) {
+		user.Role = role
+	})
+}
+
+func (s *UserService) DeactivateUser(ctx context.Context, id int64) (*pbu.User, error) {
+	return s.modifyUser(ctx, id, func(user *model.User) {
+		user.IsActive = false
+	})
+}
+
+func (s *UserService) ResetPassword(ctx context.Context, id int64, password string) (*pbu.User, error) {
+	user, err := s.getUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var v violations
+	v.checkPassword("password", s.passwordPolicy, password, user.Email, user.FullName)
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := s.hashPassword(ctx, password)
+	if err != nil {
+		return nil, toStatus(ctx, err, "hash password")
+	}
+
+	return s.modifyUser(ctx, id, func(user *model.User) {
+		user.Password = hashedPassword
+	})
+}
+
+// ImportedUser is a user migrated from another system, with the password hash
+// that system stored.
+type ImportedUser struct {
+	Email        string
+	FullName     string
+	PasswordHash string
+	Role         string
+	Locale       string
+	Timezone     string
+}
+
+// ImportUser stores the password hash as it is, in any format the password_hash
+// package can verify; it is replaced by a current hash on the first login. No
+// welcome email or user created event is sent, since the user isn't new.
+func (s *UserService) ImportUser(ctx context.Context, imported ImportedUser) (*pbu.User, error) {
+	if imported.Role == "" {
+		imported.Role = model.RoleUser
+	}
+	imported.Email = s.emails.Normalize(imported.Email)
+
+	var v violations
+	v.checkEmail("email", imported.Email)
+	v.checkFullName("full_name", imported.FullName)
+	v.checkLocale("locale", imported.Locale)
+	v.checkTimezone("timezone", imported.Timezone)
+	v.checkRole("role", imported.Role)
+	if err := password_hash.Check(imported.PasswordHash); err != nil {
+		v.add("password_hash", "%v", err)
+	}
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	user := &model.User{
+		FullName: imported.FullName,
+		Password: imported.PasswordHash,
+		Email:    imported.Email,
+		Role:     imported.Role,
+		IsActive: true,
+		Locale:   locale.DefaultLocale,
+		Timezone: locale.DefaultTimezone,
+	}
+	if imported.Locale != "" {
+		user.Locale, _ = locale.NormalizeLocale(imported.Locale)
+	}
+	if imported.Timezone != "" {
+		user.Timezone = imported.Timezone
+	}
+
+	if err := s.users.Create(ctx, user); err != nil {
+		return nil, toStatus(ctx, err, "create user")
+	}
+	return toUserProto(user), nil
+}
+
+// EmailChange is a stored email that differs from its normalized form.
+type EmailChange struct {
+	UserID int64
+	From   string
+	To     string
+}
+
+// NormalizeStoredEmails brings stored emails in line with the normalization
+// rules, e.g. after email_domain_rules changed; with apply false it only
+// reports. Users are read a page at a time and only their email column is
+// written. A user whose normalized email is already taken, by a stored user or by
+// an earlier change of the same run, is left as it is and returned along with the
+// user holding the email, for an operator to resolve.
+func (s *UserService) NormalizeStoredEmails(ctx context.Context, apply bool) (changes []EmailChange, collisions [][]int64, err error) {
+	const pageSize = 500
+
+	// claimed maps the lowercased emails changed in this run to their users.
+	claimed := map[string]int64{}
+	for offset := 0; ; offset += pageSize {
+		users, err := s.users.List(ctx, pageSize, offset)
+		if err != nil {
+			return changes, collisions, toStatus(ctx, err, "list users")
+		}
+
+		for _, user := range users {
+			normalized := s.emails.Normalize(user.Email)
+			if normalized == user.Email {
+				continue
+			}
+
+			holder, err := s.emailHolder(ctx, claimed, normalized, user.ID)
+			if err != nil {
+				return changes, collisions, toStatus(ctx, err, "find user by email")
+			}
+			if holder != 0 {
+				collisions = append(collisions, []int64{holder, user.ID})
+				continue
+			}
+
+			if apply {
+				err := s.users.SetEmail(ctx, user.ID, normalized)
+				if errors.Is(err, repository.ErrEmailTaken) {
+					// Taken since the check above.
+					holder, err := s.emailHolder(ctx, claimed, normalized, user.ID)
+					if err != nil {
+						return changes, collisions, toStatus(ctx, err, "find user by email")
+					}
+					collisions = append(collisions, []int64{holder, user.ID})
+					continue
+				}
+				if err != nil {
+					return changes, collisions, toStatus(ctx, err, fmt.Sprintf("update email of user %d", user.ID))
+				}
+			}
+			claimed[strings.ToLower(normalized)] = user.ID
+			changes = append(changes, EmailChange{UserID: user.ID, From: user.Email, To: normalized})
+		}
+
+		if len(users) < pageSize {
+			return changes, collisions, nil
+		}
+	}
+}
+
+// emailHolder returns the ID of the user other than id whose email is email, or
+// would be after the changes claimed so far, and 0 when there is none.
+func (s *UserService) emailHolder(ctx context.Context, claimed map[string]int64, email string, id int64) (int64, error) {
+	if holder, ok := claimed[strings.ToLower(email)]; ok {
+		return holder, nil
+	}
+
+	user, err := s.users.GetByEmail(ctx, email)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if user.ID == id {
+		return 0, nil
+	}
+	return user.ID, nil
+}
+
+func (s *UserService) ListUsers(ctx context.Context, limit, offset int) ([]*pbu.User, error) {
+	users, err := s.users.List(ctx, limit, offset)
+	if err != nil {
+		return nil, toStatus(ctx, err, "list users")
+	}
+
+	resp := make([]*pbu.User, 0, len(users))
+	for i := range users {
+		resp = append(resp, toUserProto(&users[i]))
+	}
+	return resp, nil
+}
+
+func (s *UserService) modifyUser(ctx context.Context, id int64, modify func(user *model.User)) (*pbu.User, error) {
+	user, err := s.getUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	modify(user)
+
+	if err := s.users.Update(ctx, user); err != nil {
+		return nil, toStatus(ctx, err, "update user")
+	}
+
+	return toUserProto(user), nil
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	pbu "ryg-user-service/gen_proto/user_service"
+	"ryg-user-service/tls_config"
+	"slices"
+)
+
+const reasonPermissionDenied = "PERMISSION_DENIED"
+
+// adminMethods operate on the email queues rather than on the caller's users.
+var adminMethods = map[string]bool{
+	pbu.UserService_ListDeadLetterEmails_FullMethodName:   true,
+	pbu.UserService_RetryDeadLetterEmail_FullMethodName:   true,
+	pbu.UserService_DiscardDeadLetterEmail_FullMethodName: true,
+}
+
+// AdminOnlyInterceptor rejects calls to the admin methods unless the client
+// presented a certificate whose identity is one of principals.
+func AdminOnlyInterceptor(principals []string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !adminMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		identity, ok := tls_config.ClientIdentity(ctx)
+		if !ok || !slices.Contains(principals, identity.Name()) {
+			return nil, newStatus(codes.PermissionDenied, reasonPermissionDenied, map[string]string{"method": info.FullMethod},
+				"%s is restricted to admin clients", info.FullMethod)
+		}
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc/codes"
+	"log/slog"
+	pbu "ryg-user-service/gen_proto/user_service"
+	"ryg-user-service/metrics"
+	"ryg-user-service/model"
+	"ryg-user-service/password_hash"
+	"ryg-user-service/repository"
+	"ryg-user-service/tracing"
+	"time"
+)
+
+// VerifyCredentials checks a login and upgrades the stored hash when it was made
+// with an outdated algorithm or cost, which is only possible while the password
+// is at hand. Unknown emails and wrong passwords get the same error.
+func (s *UserService) VerifyCredentials(ctx context.Context, req *pbu.VerifyCredentialsRequest) (*pbu.User, error) {
+	req.Email = s.emails.Normalize(req.Email)
+	if err := validateVerifyCredentialsRequest(req); err != nil {
+		return nil, err
+	}
+
+	user, err := s.users.GetByEmail(ctx, req.Email)
+	if err != nil {
+		if !errors.Is(err, repository.ErrUserNotFound) {
+			return nil, toStatus(ctx, err, "retrieve user")
+		}
+		// Hash anyway, so that the response time doesn't tell which emails exist.
+		if _, err := s.hashPassword(ctx, req.Password); err != nil {
+			return nil, toStatus(ctx, err, "hash password")
+		}
+		return nil, newStatus(codes.Unauthenticated, reasonInvalidCredentials, nil, "invalid email or password")
+	}
+
+	match, needsRehash, err := s.verifyPassword(ctx, req.Password, user.Password)
+	if err != nil {
+		return nil, toStatus(ctx, err, "verify password")
+	}
+	if !match {
+		return nil, newStatus(codes.Unauthenticated, reasonInvalidCredentials, nil, "invalid email or password")
+	}
+	if !user.IsActive {
+		return nil, newStatus(codes.PermissionDenied, reasonUserDeactivated, nil, "user is deactivated")
+	}
+
+	if needsRehash {
+		s.rehashPassword(ctx, user, req.Password)
+	}
+	return toUserProto(user), nil
+}
+
+// rehashPassword is best effort: the login has succeeded either way and the
+// next one tries again.
+func (s *UserService) rehashPassword(ctx context.Context, user *model.User, password string) {
+	hashedPassword, err := s.hashPassword(ctx, password)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to rehash password", "user_id", user.ID, "error", err)
+		return
+	}
+	replaced, err := s.users.ReplacePassword(ctx, user.ID, user.Password, hashedPassword)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to store rehashed password", "user_id", user.ID, "error", err)
+		return
+	}
+	if !replaced {
+		// The password changed or the user was deleted since it was read.
+		return
+	}
+	slog.InfoContext(ctx, "Upgraded password hash", "user_id", user.ID,
+		"from", password_hash.AlgorithmOf(user.Password), "to", s.passwordHasher.Algorithm())
+	user.Password = hashedPassword
+}
+
+func (s *UserService) hashPassword(ctx context.Context, password string) (string, error) {
+	algorithm := s.passwordHasher.Algorithm()
+	defer metrics.ObservePasswordHash(algorithm, time.Now())
+	_, span := tracing.Tracer.Start(ctx, "password_hash.Hash")
+	span.SetAttributes(attribute.String("password_hash.algorithm", algorithm))
+
+	hashedPassword, err := s.passwordHasher.Hash(password)
+	tracing.End(span, err)
+	return hashedPassword, err
+}
+
+func (s *UserService) verifyPassword(ctx context.Context, password, encoded string) (match, needsRehash bool, err error) {
+	algorithm := password_hash.AlgorithmOf(encoded)
+	defer metrics.ObservePasswordHash(algorithm, time.Now())
+	_, span := tracing.Tracer.Start(ctx, "password_hash.Verify")
+	span.SetAttributes(attribute.String("password_hash.algorithm", algorithm))
+
+	match, needsRehash, err = s.passwordHasher.Verify(password, encoded)
+	tracing.End(span, err)
+	return match, needsRehash, err
+}
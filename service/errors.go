@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"ryg-user-service/repository"
+	"ryg-user-service/retry_queue"
+)
+
+// errorDomain is the ErrorInfo domain of every error reason below.
+const errorDomain = "ryg-user-service"
+
+// Reasons of the ErrorInfo attached to errors, for clients to tell errors with the
+// same code apart.
+const (
+	reasonInvalidArgument     = "INVALID_ARGUMENT"
+	reasonUserNotFound        = "USER_NOT_FOUND"
+	reasonEmailTaken          = "EMAIL_TAKEN"
+	reasonDeadLetterNotFound  = "DEAD_LETTER_NOT_FOUND"
+	reasonRecipientSuppressed = "RECIPIENT_SUPPRESSED"
+	reasonInvalidCredentials  = "INVALID_CREDENTIALS"
+	reasonUserDeactivated     = "USER_DEACTIVATED"
+	reasonUniqueViolation     = "UNIQUE_VIOLATION"
+	reasonCheckViolation      = "CHECK_VIOLATION"
+	reasonCanceled            = "CANCELED"
+	reasonDeadlineExceeded    = "DEADLINE_EXCEEDED"
+	reasonInternal            = "INTERNAL"
+)
+
+// Postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html.
+const (
+	pgUniqueViolation = "23505"
+	pgCheckViolation  = "23514"
+)
+
+// newStatus returns a status error carrying an ErrorInfo with reason.
+func newStatus(code codes.Code, reason string, metadata map[string]string, format string, args ...any) error {
+	st := status.New(code, fmt.Sprintf(format, args...))
+	detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   errorDomain,
+		Metadata: metadata,
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// toStatus translates an error of the repository or the layers below into a
+// status error, so that every method reports the same failure the same way.
+// Status errors pass through. Internal errors are logged and only doing, e.g.
+// "update user", reaches the client, never the SQL or driver error text.
+func toStatus(ctx context.Context, err error, doing string) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, repository.ErrUserNotFound):
+		return newStatus(codes.NotFound, reasonUserNotFound, nil, "user not found")
+	case errors.Is(err, repository.ErrEmailTaken):
+		return newStatus(codes.AlreadyExists, reasonEmailTaken, nil, "email is already taken")
+	case errors.Is(err, retry_queue.ErrDeadLetterNotFound):
+		return newStatus(codes.NotFound, reasonDeadLetterNotFound, nil, "dead letter email not found")
+	case errors.Is(err, retry_queue.ErrRecipientSuppressed):
+		return newStatus(codes.FailedPrecondition, reasonRecipientSuppressed, nil, "recipient address bounced or complained")
+	case errors.Is(err, context.Canceled):
+		return newStatus(codes.Canceled, reasonCanceled, nil, "failed to %s: request canceled", doing)
+	case errors.Is(err, context.DeadlineExceeded):
+		return newStatus(codes.DeadlineExceeded, reasonDeadlineExceeded, nil, "failed to %s: deadline exceeded", doing)
+	case errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation:
+		return newStatus(codes.AlreadyExists, reasonUniqueViolation, map[string]string{"constraint": pgErr.ConstraintName},
+			"failed to %s: a conflicting record already exists", doing)
+	case errors.As(err, &pgErr) && pgErr.Code == pgCheckViolation:
+		return newStatus(codes.InvalidArgument, reasonCheckViolation, map[string]string{"constraint": pgErr.ConstraintName},
+			"failed to %s: a value is not allowed", doing)
+	default:
+		slog.ErrorContext(ctx, "Failed to "+doing, "error", err)
+		return newStatus(codes.Internal, reasonInternal, nil, "failed to %s", doing)
+	}
+}
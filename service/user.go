@@ -2,103 +2,169 @@ package service
 
 import (
 	"context"
-	"errors"
-	"golang.org/x/crypto/bcrypt"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"fmt"
 	"google.golang.org/protobuf/types/known/emptypb"
-	"gorm.io/gorm"
-	"log"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"log/slog"
+	"ryg-user-service/email_address"
 	pbe "ryg-user-service/gen_proto/email_service"
 	pbu "ryg-user-service/gen_proto/user_service"
+	"ryg-user-service/locale"
 	"ryg-user-service/model"
+	"ryg-user-service/password_hash"
+	"ryg-user-service/password_policy"
 	"ryg-user-service/rabbit_mq"
+	"ryg-user-service/repository"
+	"strings"
 )
 
+const maxDeadLetterPageSize = 100
+
+// EmailRetryQueue keeps emails that failed to publish and delivers them later,
+// see retry_queue.EmailRetryQueue.
+type EmailRetryQueue interface {
+	Enqueue(ctx context.Context, messageID string, email *pbe.GenericEmail, critical bool, publishErr error) error
+	ListDeadLetters(ctx context.Context, limit, offset int) ([]model.DeadLetterEmail, error)
+	RetryDeadLetter(ctx context.Context, id int64) error
+	DiscardDeadLetter(ctx context.Context, id int64) error
+}
+
 type UserService struct {
-	db                    *gorm.DB
-	genericEmailPublisher *rabbit_mq.GenericEmailPublisher
+	users                 repository.UserRepository
+	genericEmailPublisher rabbit_mq.Publisher[*pbe.GenericEmail]
+	userCreatedPublisher  rabbit_mq.Publisher[*pbu.User]
+	emailRetryQueue       EmailRetryQueue
+	passwordPolicy        *password_policy.Policy
+	passwordHasher        *password_hash.Hasher
+	emails                *email_address.Normalizer
 	pbu.UnimplementedUserServiceServer
 }
 
-func NewUserService(db *gorm.DB, genericEmailPublisher *rabbit_mq.GenericEmailPublisher) *UserService {
+func NewUserService(
+	users repository.UserRepository,
+	genericEmailPublisher rabbit_mq.Publisher[*pbe.GenericEmail],
+	userCreatedPublisher rabbit_mq.Publisher[*pbu.User],
+	emailRetryQueue EmailRetryQueue,
+	passwordPolicy *password_policy.Policy,
+	passwordHasher *password_hash.Hasher,
+	emails *email_address.Normalizer,
+) *UserService {
 	return &UserService{
-		db:                    db,
+		users:                 users,
 		genericEmailPublisher: genericEmailPublisher,
+		userCreatedPublisher:  userCreatedPublisher,
+		emailRetryQueue:       emailRetryQueue,
+		passwordPolicy:        passwordPolicy,
+		passwordHasher:        passwordHasher,
+		emails:                emails,
 	}
 }
 
 func (s *UserService) CreateUser(ctx context.Context, req *pbu.CreateUserRequest) (*pbu.User, error) {
-	hashedPassword, err := hashPassword(req.Password)
-	if err != nil {
+	return s.createUser(ctx, req, model.RoleUser)
+}
+
+func (s *UserService) createUser(ctx context.Context, req *pbu.CreateUserRequest, role string) (*pbu.User, error) {
+	// Emails are normalized before validation, which then sees what is stored.
+	req.Email = s.emails.Normalize(req.Email)
+	if err := validateCreateUserRequest(req, s.passwordPolicy); err != nil {
 		return nil, err
 	}
 
+	// The locale and the timezone were validated above.
+	userLocale, userTimezone := locale.DefaultLocale, locale.DefaultTimezone
+	if req.Locale != "" {
+		userLocale, _ = locale.NormalizeLocale(req.Locale)
+	}
+	if req.Timezone != "" {
+		userTimezone = req.Timezone
+	}
+
+	hashedPassword, err := s.hashPassword(ctx, req.Password)
+	if err != nil {
+		return nil, toStatus(ctx, err, "hash password")
+	}
+
 	user := &model.User{
 		FullName: req.FullName,
 		Password: hashedPassword,
 		Email:    req.Email,
-		Role:     "user",
+		Role:     role,
+		Locale:   userLocale,
+		Timezone: userTimezone,
 	}
 
-	if err := s.db.WithContext(ctx).Create(&user).Error; err != nil {
-		return nil, err
+	if err := s.users.Create(ctx, user); err != nil {
+		return nil, toStatus(ctx, err, "create user")
 	}
 
-	resp := &pbu.User{
-		Id:       user.ID,
-		FullName: user.FullName,
-		Email:    user.Email,
-		Role:     user.Role,
-		IsActive: user.IsActive,
+	resp := toUserProto(user)
+
+	if err := s.userCreatedPublisher.Publish(ctx, resp); err != nil {
+		slog.WarnContext(ctx, "Failed to publish user created event", "user_id", user.ID, "error", err)
 	}
 
-	err = s.genericEmailPublisher.Publish(&pbe.GenericEmail{
+	welcomeEmail := locale.WelcomeEmail(user.Locale)
+	s.sendEmail(ctx, &pbe.GenericEmail{
 		To:      user.Email,
-		Subject: "Welcome to RYG",
-		Body:    "Welcome to RYG, we are glad to have you on board!",
-	})
-
-	if err != nil {
-		log.Printf("Failed to publish email: %v", err)
-	}
+		Subject: welcomeEmail.Subject,
+		Body:    welcomeEmail.Body,
+	}, false)
 
 	return resp, nil
 }
 
-func hashPassword(password string) (string, error) {
-	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
+// sendEmail hands the email to the retry queue when the broker rejects it, so a
+// broker outage never fails the RPC that triggered the email. Non-critical emails
+// are dropped for addresses that bounced or complained, here and by the retry
+// queue.
+func (s *UserService) sendEmail(ctx context.Context, email *pbe.GenericEmail, critical bool) {
+	if !critical {
+		suppressed, err := s.users.EmailSuppressed(ctx, email.To)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to check whether the email address is suppressed", "error", err)
+			return
+		}
+		if suppressed {
+			slog.InfoContext(ctx, "Skipping email to undeliverable address")
+			return
+		}
+	}
+
+	messageID := rabbit_mq.NewMessageID()
+	err := s.genericEmailPublisher.Publish(rabbit_mq.WithMessageID(ctx, messageID), email)
+	if err == nil {
+		return
+	}
+
+	slog.WarnContext(ctx, "Failed to publish email, scheduling retry", "message_id", messageID, "error", err)
+	if err := s.emailRetryQueue.Enqueue(ctx, messageID, email, critical, err); err != nil {
+		slog.ErrorContext(ctx, "Failed to enqueue email for retry", "message_id", messageID, "error", err)
 	}
-	return string(hashedBytes), nil
 }
 
 func (s *UserService) GetUserById(ctx context.Context, req *pbu.GetUserRequest) (*pbu.User, error) {
-	var user model.User
-	if err := s.db.WithContext(ctx).First(&user, req.Id).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, status.Errorf(codes.NotFound, "user not found")
-		}
-		return nil, status.Errorf(codes.Internal, "failed to retrieve user: %v", err)
+	if err := validateGetUserRequest(req); err != nil {
+		return nil, err
 	}
 
-	return &pbu.User{
-		Id:       user.ID,
-		FullName: user.FullName,
-		Email:    user.Email,
-		Role:     user.Role,
-		IsActive: user.IsActive,
-	}, nil
+	user, err := s.getUser(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return toUserProto(user), nil
 }
 
 func (s *UserService) GetUserForLogin(ctx context.Context, req *pbu.GetUserForLoginRequest) (*pbu.UserForLogin, error) {
-	var user model.User
-	if err := s.db.WithContext(ctx).Where("email = ?", req.Email).First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, status.Errorf(codes.NotFound, "user not found")
-		}
-		return nil, status.Errorf(codes.Internal, "failed to retrieve user: %v", err)
+	req.Email = s.emails.Normalize(req.Email)
+	if err := validateGetUserForLoginRequest(req); err != nil {
+		return nil, err
+	}
+
+	user, err := s.users.GetByEmail(ctx, req.Email)
+	if err != nil {
+		return nil, toStatus(ctx, err, "retrieve user")
 	}
 
 	return &pbu.UserForLogin{
@@ -110,32 +176,132 @@ func (s *UserService) GetUserForLogin(ctx context.Context, req *pbu.GetUserForLo
 }
 
 func (s *UserService) UpdateUser(ctx context.Context, req *pbu.UpdateUserRequest) (*pbu.User, error) {
-	var user model.User
-	if err := s.db.WithContext(ctx).First(&user, req.Id).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, status.Errorf(codes.NotFound, "user not found")
-		}
-		return nil, status.Errorf(codes.Internal, "failed to retrieve user: %v", err)
+	if err := validateUpdateUserRequest(req); err != nil {
+		return nil, err
+	}
+
+	user, err := s.getUser(ctx, req.Id)
+	if err != nil {
+		return nil, err
 	}
 
-	user.FullName = req.FullName
+	// Fields left empty keep their value.
+	if req.FullName != "" {
+		user.FullName = req.FullName
+	}
+	if req.Email != "" {
+		email := s.emails.Normalize(req.Email)
+		if !strings.EqualFold(email, user.Email) {
+			// Bounces and complaints were about the previous address.
+			user.EmailStatus = model.EmailStatusDeliverable
+		}
+		user.Email = email
+	}
+	if req.Locale != "" {
+		user.Locale, _ = locale.NormalizeLocale(req.Locale)
+	}
+	if req.Timezone != "" {
+		user.Timezone = req.Timezone
+	}
 
-	if err := s.db.WithContext(ctx).Save(&user).Error; err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to update user: %v", err)
+	if err := s.users.Update(ctx, user); err != nil {
+		return nil, toStatus(ctx, err, "update user")
 	}
 
-	return &pbu.User{
-		Id:       user.ID,
-		FullName: user.FullName,
-		Email:    user.Email,
-		Role:     user.Role,
-		IsActive: user.IsActive,
-	}, nil
+	return toUserProto(user), nil
 }
 
 func (s *UserService) DeleteUser(ctx context.Context, req *pbu.DeleteUserRequest) (*emptypb.Empty, error) {
-	if err := s.db.WithContext(ctx).Delete(&model.User{}, req.Id).Error; err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to delete user: %v", err)
+	if err := validateDeleteUserRequest(req); err != nil {
+		return nil, err
+	}
+
+	if err := s.users.Delete(ctx, req.Id); err != nil {
+		return nil, toStatus(ctx, err, "delete user")
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// MarkEmailUndeliverable records a bounce or complaint reported by the email service.
+// Unknown addresses are ignored since the user may have been deleted in the meantime.
+func (s *UserService) MarkEmailUndeliverable(ctx context.Context, email, emailStatus string) error {
+	if err := s.users.SetEmailStatus(ctx, s.emails.Normalize(email), emailStatus); err != nil {
+		return fmt.Errorf("failed to update email status: %w", err)
+	}
+	return nil
+}
+
+func (s *UserService) ListDeadLetterEmails(ctx context.Context, req *pbu.ListDeadLetterEmailsRequest) (*pbu.ListDeadLetterEmailsResponse, error) {
+	if err := validateListDeadLetterEmailsRequest(req); err != nil {
+		return nil, err
+	}
+
+	limit := int(req.Limit)
+	if limit == 0 || limit > maxDeadLetterPageSize {
+		limit = maxDeadLetterPageSize
+	}
+
+	deadLetters, err := s.emailRetryQueue.ListDeadLetters(ctx, limit, int(req.Offset))
+	if err != nil {
+		return nil, toStatus(ctx, err, "list dead letter emails")
+	}
+
+	resp := &pbu.ListDeadLetterEmailsResponse{
+		Emails: make([]*pbu.DeadLetterEmail, 0, len(deadLetters)),
+	}
+	for _, deadLetter := range deadLetters {
+		resp.Emails = append(resp.Emails, &pbu.DeadLetterEmail{
+			Id:        deadLetter.ID,
+			To:        deadLetter.To,
+			Subject:   deadLetter.Subject,
+			Body:      deadLetter.Body,
+			Attempts:  int32(deadLetter.Attempts),
+			LastError: deadLetter.LastError,
+			FailedAt:  timestamppb.New(deadLetter.FailedAt),
+		})
+	}
+	return resp, nil
+}
+
+func (s *UserService) RetryDeadLetterEmail(ctx context.Context, req *pbu.DeadLetterEmailRequest) (*emptypb.Empty, error) {
+	if err := validateDeadLetterEmailRequest(req); err != nil {
+		return nil, err
+	}
+
+	if err := s.emailRetryQueue.RetryDeadLetter(ctx, req.Id); err != nil {
+		return nil, toStatus(ctx, err, "retry dead letter email")
 	}
 	return &emptypb.Empty{}, nil
 }
+
+func (s *UserService) DiscardDeadLetterEmail(ctx context.Context, req *pbu.DeadLetterEmailRequest) (*emptypb.Empty, error) {
+	if err := validateDeadLetterEmailRequest(req); err != nil {
+		return nil, err
+	}
+
+	if err := s.emailRetryQueue.DiscardDeadLetter(ctx, req.Id); err != nil {
+		return nil, toStatus(ctx, err, "discard dead letter email")
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *UserService) getUser(ctx context.Context, id int64) (*model.User, error) {
+	user, err := s.users.GetByID(ctx, id)
+	if err != nil {
+		return nil, toStatus(ctx, err, "retrieve user")
+	}
+	return user, nil
+}
+
+func toUserProto(user *model.User) *pbu.User {
+	return &pbu.User{
+		Id:          user.ID,
+		FullName:    user.FullName,
+		Email:       user.Email,
+		Role:        user.Role,
+		IsActive:    user.IsActive,
+		Locale:      user.Locale,
+		Timezone:    user.Timezone,
+		EmailStatus: user.EmailStatus,
+	}
+}
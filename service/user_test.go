@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"ryg-user-service/conf"
+	"ryg-user-service/email_address"
+	pbe "ryg-user-service/gen_proto/email_service"
+	pbu "ryg-user-service/gen_proto/user_service"
+	"ryg-user-service/model"
+	"ryg-user-service/password_hash"
+	"ryg-user-service/password_policy"
+	"ryg-user-service/rabbit_mq"
+	"ryg-user-service/repository"
+	"testing"
+)
+
+const testPassword = "correct horse battery staple"
+
+type enqueuedEmail struct {
+	email    *pbe.GenericEmail
+	critical bool
+}
+
+// recordingRetryQueue keeps enqueued emails and has no dead letters.
+type recordingRetryQueue struct {
+	enqueued []enqueuedEmail
+}
+
+func (q *recordingRetryQueue) Enqueue(_ context.Context, _ string, email *pbe.GenericEmail, critical bool, _ error) error {
+	q.enqueued = append(q.enqueued, enqueuedEmail{email: email, critical: critical})
+	return nil
+}
+
+func (q *recordingRetryQueue) ListDeadLetters(context.Context, int, int) ([]model.DeadLetterEmail, error) {
+	return nil, nil
+}
+
+func (q *recordingRetryQueue) RetryDeadLetter(context.Context, int64) error {
+	return nil
+}
+
+func (q *recordingRetryQueue) DiscardDeadLetter(context.Context, int64) error {
+	return nil
+}
+
+type failingPublisher[T any] struct{}
+
+func (failingPublisher[T]) Publish(context.Context, T) error {
+	return errors.New("broker unavailable")
+}
+
+type testService struct {
+	*UserService
+	users       *repository.MemoryUserRepository
+	emails      *rabbit_mq.RecordingPublisher[*pbe.GenericEmail]
+	userCreated *rabbit_mq.RecordingPublisher[*pbu.User]
+	retryQueue  *recordingRetryQueue
+}
+
+func newTestService(t *testing.T, emailPublisher rabbit_mq.Publisher[*pbe.GenericEmail]) *testService {
+	t.Helper()
+
+	policy, err := password_policy.New(conf.Default().PasswordPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := &testService{
+		users:       repository.NewMemoryUserRepository(),
+		emails:      rabbit_mq.NewRecordingPublisher[*pbe.GenericEmail](),
+		userCreated: rabbit_mq.NewRecordingPublisher[*pbu.User](),
+		retryQueue:  &recordingRetryQueue{},
+	}
+	if emailPublisher == nil {
+		emailPublisher = ts.emails
+	}
+	hasher := password_hash.New(conf.PasswordHashingConfig{Algorithm: conf.PasswordHashBcrypt, BcryptCost: bcrypt.MinCost})
+	normalizer := email_address.NewNormalizer([]conf.EmailDomainRule{
+		{Domains: []string{"gmail.com"}, IgnoreDots: true, StripPlusTags: true},
+	})
+	ts.UserService = NewUserService(ts.users, emailPublisher, ts.userCreated, ts.retryQueue, policy, hasher, normalizer)
+	return ts
+}
+
+func (ts *testService) createUser(t *testing.T, email string) *pbu.User {
+	t.Helper()
+
+	user, err := ts.CreateUser(context.Background(), &pbu.CreateUserRequest{
+		Email:    email,
+		FullName: "Jo Doe",
+		Password: testPassword,
+	})
+	if err != nil {
+		t.Fatalf("CreateUser(%q) failed: %v", email, err)
+	}
+	return user
+}
+
+// errorInfo returns the ErrorInfo of a status error, if any.
+func errorInfo(err error) *errdetails.ErrorInfo {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info
+		}
+	}
+	return nil
+}
+
+func assertStatus(t *testing.T, err error, code codes.Code, reason string) {
+	t.Helper()
+
+	if got := status.Code(err); got != code {
+		t.Fatalf("got code %v, want %v: %v", got, code, err)
+	}
+	info := errorInfo(err)
+	if info == nil || info.Reason != reason || info.Domain != errorDomain {
+		t.Fatalf("got ErrorInfo %v, want reason %s", info, reason)
+	}
+}
+
+func TestCreateUser(t *testing.T) {
+	ts := newTestService(t, nil)
+
+	user := ts.createUser(t, " Jo.Doe+news@GMAIL.com ")
+	if user.Email != "JoDoe@gmail.com" {
+		t.Errorf("got email %q, want the normalized JoDoe@gmail.com", user.Email)
+	}
+	if user.Role != model.RoleUser || !user.IsActive {
+		t.Errorf("got role %q and active %t, want an active user", user.Role, user.IsActive)
+	}
+
+	stored, err := ts.users.GetByID(context.Background(), user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Password == testPassword || password_hash.AlgorithmOf(stored.Password) != conf.PasswordHashBcrypt {
+		t.Errorf("got stored password %q, want a bcrypt hash", stored.Password)
+	}
+
+	if created := ts.userCreated.Messages(); len(created) != 1 || created[0].Id != user.Id {
+		t.Errorf("got user created events %v, want one for user %d", created, user.Id)
+	}
+	if emails := ts.emails.Messages(); len(emails) != 1 || emails[0].To != user.Email {
+		t.Errorf("got emails %v, want a welcome email to %s", emails, user.Email)
+	}
+}
+
+func TestCreateUserEmailTaken(t *testing.T) {
+	ts := newTestService(t, nil)
+	ts.createUser(t, "jo@example.com")
+
+	_, err := ts.CreateUser(context.Background(), &pbu.CreateUserRequest{
+		Email:    "JO@example.com",
+		FullName: "Jo Doe",
+		Password: testPassword,
+	})
+	assertStatus(t, err, codes.AlreadyExists, reasonEmailTaken)
+}
+
+func TestCreateUserViolations(t *testing.T) {
+	ts := newTestService(t, nil)
+
+	_, err := ts.CreateUser(context.Background(), &pbu.CreateUserRequest{
+		Email:    "not an email",
+		FullName: "Jo\x00",
+		Password: testPassword,
+	})
+	assertStatus(t, err, codes.InvalidArgument, reasonInvalidArgument)
+
+	var fields []string
+	for _, detail := range status.Convert(err).Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, violation := range badRequest.FieldViolations {
+				fields = append(fields, violation.Field)
+			}
+		}
+	}
+	if len(fields) != 2 || fields[0] != "email" || fields[1] != "fullName" {
+		t.Errorf("got violations of %v, want email and fullName", fields)
+	}
+}
+
+func TestCreateUserEnqueuesEmailWhenPublishFails(t *testing.T) {
+	ts := newTestService(t, failingPublisher[*pbe.GenericEmail]{})
+
+	user := ts.createUser(t, "jo@example.com")
+	if len(ts.retryQueue.enqueued) != 1 {
+		t.Fatalf("got %d enqueued emails, want 1", len(ts.retryQueue.enqueued))
+	}
+	enqueued := ts.retryQueue.enqueued[0]
+	if enqueued.email.To != user.Email || enqueued.critical {
+		t.Errorf("got enqueued email to %s, critical %t, want the welcome email to %s", enqueued.email.To, enqueued.critical, user.Email)
+	}
+}
+
+func TestVerifyCredentials(t *testing.T) {
+	ts := newTestService(t, nil)
+	user := ts.createUser(t, "jo@example.com")
+	ctx := context.Background()
+
+	got, err := ts.VerifyCredentials(ctx, &pbu.VerifyCredentialsRequest{Email: "JO@example.com", Password: testPassword})
+	if err != nil {
+		t.Fatalf("VerifyCredentials failed: %v", err)
+	}
+	if got.Id != user.Id {
+		t.Errorf("got user %d, want %d", got.Id, user.Id)
+	}
+
+	_, err = ts.VerifyCredentials(ctx, &pbu.VerifyCredentialsRequest{Email: "jo@example.com", Password: "wrong password"})
+	assertStatus(t, err, codes.Unauthenticated, reasonInvalidCredentials)
+
+	_, err = ts.VerifyCredentials(ctx, &pbu.VerifyCredentialsRequest{Email: "nobody@example.com", Password: testPassword})
+	assertStatus(t, err, codes.Unauthenticated, reasonInvalidCredentials)
+
+	if _, err := ts.DeactivateUser(ctx, user.Id); err != nil {
+		t.Fatal(err)
+	}
+	_, err = ts.VerifyCredentials(ctx, &pbu.VerifyCredentialsRequest{Email: "jo@example.com", Password: testPassword})
+	assertStatus(t, err, codes.PermissionDenied, reasonUserDeactivated)
+}
+
+func TestUpdateUserEmail(t *testing.T) {
+	ts := newTestService(t, nil)
+	user := ts.createUser(t, "jo@example.com")
+	ts.createUser(t, "taken@example.com")
+	ctx := context.Background()
+
+	if err := ts.MarkEmailUndeliverable(ctx, user.Email, model.EmailStatusBounced); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := ts.UpdateUser(ctx, &pbu.UpdateUserRequest{Id: user.Id, FullName: "Jo Doe", Email: "Jo.Doe@GMAIL.com"})
+	if err != nil {
+		t.Fatalf("UpdateUser failed: %v", err)
+	}
+	if updated.Email != "JoDoe@gmail.com" {
+		t.Errorf("got email %q, want the normalized JoDoe@gmail.com", updated.Email)
+	}
+	if updated.EmailStatus != model.EmailStatusDeliverable {
+		t.Errorf("got email status %q for the new address, want %q", updated.EmailStatus, model.EmailStatusDeliverable)
+	}
+
+	_, err = ts.UpdateUser(ctx, &pbu.UpdateUserRequest{Id: user.Id, FullName: "Jo Doe", Email: "TAKEN@example.com"})
+	assertStatus(t, err, codes.AlreadyExists, reasonEmailTaken)
+}
+
+func TestUpdateUserKeepsOmittedFields(t *testing.T) {
+	ts := newTestService(t, nil)
+	user := ts.createUser(t, "jo@example.com")
+	ctx := context.Background()
+
+	updated, err := ts.UpdateUser(ctx, &pbu.UpdateUserRequest{Id: user.Id, Locale: "de-de"})
+	if err != nil {
+		t.Fatalf("UpdateUser failed: %v", err)
+	}
+	if updated.Locale != "de-DE" || updated.FullName != user.FullName || updated.Email != user.Email || updated.Timezone != user.Timezone {
+		t.Errorf("got %v, want only the locale of %v changed to de-DE", updated, user)
+	}
+
+	// The tag is valid, but its canonical form doesn't fit the locale column.
+	_, err = ts.UpdateUser(ctx, &pbu.UpdateUserRequest{Id: user.Id, Locale: "en-US-x-aaaaaaaa-bbbbbbbb-cccccccc-dddddddd"})
+	assertStatus(t, err, codes.InvalidArgument, reasonInvalidArgument)
+}
+
+func TestNormalizeStoredEmails(t *testing.T) {
+	ts := newTestService(t, nil)
+	ctx := context.Background()
+	// Stored before the Gmail rule applied.
+	for _, email := range []string{"a.b@gmail.com", "ab+news@gmail.com", "c.d@gmail.com", "other@example.com"} {
+		if err := ts.users.Create(ctx, &model.User{Email: email, Role: model.RoleUser}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, apply := range []bool{false, true} {
+		changes, collisions, err := ts.NormalizeStoredEmails(ctx, apply)
+		if err != nil {
+			t.Fatalf("NormalizeStoredEmails(%t) failed: %v", apply, err)
+		}
+		if len(changes) != 2 || changes[0].To != "ab@gmail.com" || changes[1].To != "cd@gmail.com" {
+			t.Errorf("NormalizeStoredEmails(%t) got changes %v, want ab@gmail.com and cd@gmail.com", apply, changes)
+		}
+		if len(collisions) != 1 || len(collisions[0]) != 2 || collisions[0][0] != 1 || collisions[0][1] != 2 {
+			t.Errorf("NormalizeStoredEmails(%t) got collisions %v, want [[1 2]]", apply, collisions)
+		}
+	}
+
+	users, err := ts.users.List(ctx, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"ab@gmail.com", "ab+news@gmail.com", "cd@gmail.com", "other@example.com"}
+	for i, user := range users {
+		if user.Email != want[i] {
+			t.Errorf("got email %q for user %d, want %q", user.Email, user.ID, want[i])
+		}
+	}
+}
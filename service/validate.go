@@ -0,0 +1,202 @@
+package service
+
+import (
+	"fmt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"net/mail"
+	pbu "ryg-user-service/gen_proto/user_service"
+	"ryg-user-service/locale"
+	"ryg-user-service/model"
+	"ryg-user-service/password_policy"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	maxEmailLength    = 254
+	maxFullNameLength = 200
+)
+
+// violations collects every problem of a request, so that a form can show them
+// all at once. Fields of a request message are reported by their JSON names, e.g.
+// fullName, which both gRPC clients and the HTTP gateway see; the zero value, for
+// input that isn't a message, reports fields as they are named.
+type violations struct {
+	fields protoreflect.FieldDescriptors
+	list   []*errdetails.BadRequest_FieldViolation
+}
+
+func newViolations(req proto.Message) violations {
+	return violations{fields: req.ProtoReflect().Descriptor().Fields()}
+}
+
+// add takes the proto name of field.
+func (v *violations) add(field, format string, args ...any) {
+	if v.fields != nil {
+		if fd := v.fields.ByName(protoreflect.Name(field)); fd != nil {
+			field = fd.JSONName()
+		}
+	}
+	v.list = append(v.list, &errdetails.BadRequest_FieldViolation{
+		Field:       field,
+		Description: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v violations) err() error {
+	if len(v.list) == 0 {
+		return nil
+	}
+
+	first := v.list[0]
+	st := status.New(codes.InvalidArgument, "invalid request: "+first.Field+": "+first.Description)
+	detailed, err := st.WithDetails(
+		&errdetails.BadRequest{FieldViolations: v.list},
+		&errdetails.ErrorInfo{Reason: reasonInvalidArgument, Domain: errorDomain},
+	)
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+func (v *violations) checkID(field string, id int64) {
+	if id <= 0 {
+		v.add(field, "must be a positive id")
+	}
+}
+
+func (v *violations) checkEmail(field, email string) {
+	switch {
+	case email == "":
+		v.add(field, "is required")
+	case len(email) > maxEmailLength:
+		v.add(field, "must be at most %d characters", maxEmailLength)
+	default:
+		// ParseAddress accepts display names too, e.g. "Jo <jo@example.com>".
+		addr, err := mail.ParseAddress(email)
+		if err != nil || addr.Address != email || !strings.Contains(email[strings.LastIndex(email, "@"):], ".") {
+			v.add(field, "must be an email address")
+		}
+	}
+}
+
+// checkPassword applies the password policy; email and fullName are those of
+// the user the password is for.
+func (v *violations) checkPassword(field string, policy *password_policy.Policy, password, email, fullName string) {
+	for _, problem := range policy.Check(password, email, fullName) {
+		v.add(field, "%s", problem)
+	}
+}
+
+func (v *violations) checkFullName(field, fullName string) {
+	if utf8.RuneCountInString(fullName) > maxFullNameLength {
+		v.add(field, "must be at most %d characters", maxFullNameLength)
+		return
+	}
+	for _, r := range fullName {
+		if unicode.IsControl(r) {
+			v.add(field, "must not contain control characters")
+			return
+		}
+	}
+}
+
+func (v *violations) checkRole(field, role string) {
+	switch role {
+	case model.RoleAdmin, model.RoleUser, model.RoleProUser:
+	default:
+		v.add(field, "must be %s, %s or %s", model.RoleAdmin, model.RoleUser, model.RoleProUser)
+	}
+}
+
+func (v *violations) checkLocale(field, userLocale string) {
+	if userLocale == "" {
+		return
+	}
+	if _, err := locale.NormalizeLocale(userLocale); err != nil {
+		v.add(field, "%v", err)
+	}
+}
+
+func (v *violations) checkTimezone(field, timezone string) {
+	if timezone == "" {
+		return
+	}
+	if err := locale.ValidateTimezone(timezone); err != nil {
+		v.add(field, "%v", err)
+	}
+}
+
+func validateCreateUserRequest(req *pbu.CreateUserRequest, policy *password_policy.Policy) error {
+	v := newViolations(req)
+	v.checkEmail("email", req.Email)
+	v.checkPassword("password", policy, req.Password, req.Email, req.FullName)
+	v.checkFullName("full_name", req.FullName)
+	v.checkLocale("locale", req.Locale)
+	v.checkTimezone("timezone", req.Timezone)
+	return v.err()
+}
+
+// validateVerifyCredentialsRequest leaves out the password policy, which may
+// have changed since the password was set.
+func validateVerifyCredentialsRequest(req *pbu.VerifyCredentialsRequest) error {
+	v := newViolations(req)
+	v.checkEmail("email", req.Email)
+	if req.Password == "" {
+		v.add("password", "is required")
+	}
+	return v.err()
+}
+
+func validateUpdateUserRequest(req *pbu.UpdateUserRequest) error {
+	v := newViolations(req)
+	v.checkID("id", req.Id)
+	if req.Email != "" {
+		v.checkEmail("email", req.Email)
+	}
+	v.checkFullName("full_name", req.FullName)
+	v.checkLocale("locale", req.Locale)
+	v.checkTimezone("timezone", req.Timezone)
+	return v.err()
+}
+
+func validateGetUserRequest(req *pbu.GetUserRequest) error {
+	v := newViolations(req)
+	v.checkID("id", req.Id)
+	return v.err()
+}
+
+func validateGetUserForLoginRequest(req *pbu.GetUserForLoginRequest) error {
+	v := newViolations(req)
+	v.checkEmail("email", req.Email)
+	return v.err()
+}
+
+func validateDeleteUserRequest(req *pbu.DeleteUserRequest) error {
+	v := newViolations(req)
+	v.checkID("id", req.Id)
+	return v.err()
+}
+
+func validateListDeadLetterEmailsRequest(req *pbu.ListDeadLetterEmailsRequest) error {
+	v := newViolations(req)
+	if req.Limit < 0 {
+		v.add("limit", "must not be negative")
+	}
+	if req.Offset < 0 {
+		v.add("offset", "must not be negative")
+	}
+	return v.err()
+}
+
+func validateDeadLetterEmailRequest(req *pbu.DeadLetterEmailRequest) error {
+	v := newViolations(req)
+	v.checkID("id", req.Id)
+	return v.err()
+}
@@ -0,0 +1,60 @@
+package tls_config
+
+import (
+	"context"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// Identity describes the verified client certificate of a mutual TLS connection.
+type Identity struct {
+	CommonName string
+	DNSNames   []string
+	// URIs holds the URI SANs, e.g. spiffe://cluster.local/ns/default/sa/auth-service.
+	URIs []string
+}
+
+// Name is the most specific name of the client: its first URI SAN, its first DNS
+// SAN or its common name.
+func (i Identity) Name() string {
+	if len(i.URIs) > 0 {
+		return i.URIs[0]
+	}
+	if len(i.DNSNames) > 0 {
+		return i.DNSNames[0]
+	}
+	return i.CommonName
+}
+
+// LoopbackServerName is the server name the gateway asks for. The gateway
+// presents the server certificate, which identifies the server rather than the
+// HTTP client the gateway calls on behalf of.
+const LoopbackServerName = "loopback.ryg-user-service.internal"
+
+// ClientIdentity returns the identity of the client calling the RPC handled with
+// ctx; ok is false unless the client presented a verified certificate, and for
+// calls of the gateway. Asking for LoopbackServerName only ever drops the
+// identity of a client, so other clients gain nothing by doing so.
+func ClientIdentity(ctx context.Context) (Identity, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return Identity{}, false
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return Identity{}, false
+	}
+	if tlsInfo.State.ServerName == LoopbackServerName {
+		return Identity{}, false
+	}
+
+	cert := tlsInfo.State.VerifiedChains[0][0]
+	identity := Identity{
+		CommonName: cert.Subject.CommonName,
+		DNSNames:   cert.DNSNames,
+	}
+	for _, uri := range cert.URIs {
+		identity.URIs = append(identity.URIs, uri.String())
+	}
+	return identity, true
+}
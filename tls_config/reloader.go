@@ -0,0 +1,184 @@
+package tls_config
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"ryg-user-service/conf"
+	"sync"
+)
+
+// Reloader holds the server certificate and the client CAs and reloads them from
+// their files on request, so that renewed certificates apply to new connections
+// without a restart.
+type Reloader struct {
+	cnf conf.TLSConfig
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	// loopback is set once the gateway uses the certificate as a client.
+	loopback bool
+}
+
+func NewReloader(cnf conf.TLSConfig) (*Reloader, error) {
+	r := &Reloader{cnf: cnf}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the files again. The previous certificate is kept when they can't
+// be loaded, e.g. when only the certificate of a new key pair was written yet.
+func (r *Reloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.cnf.CertFile, r.cnf.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load the TLS key pair: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if r.cnf.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.cnf.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read the client CA file: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return errors.New("no certificates found in the client CA file")
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.loopback {
+		if err := verifyLoopbackClient(&cert, clientCAs); err != nil {
+			return err
+		}
+	}
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	slog.Info("Loaded TLS certificate", "subject", cert.Leaf.Subject.String())
+	return nil
+}
+
+// Files lists the files to watch for changes.
+func (r *Reloader) Files() []string {
+	files := []string{r.cnf.CertFile, r.cnf.KeyFile}
+	if r.cnf.ClientCAFile != "" {
+		files = append(files, r.cnf.ClientCAFile)
+	}
+	return files
+}
+
+// MutualTLS reports whether clients must present a certificate.
+func (r *Reloader) MutualTLS() bool {
+	return r.cnf.ClientCAFile != ""
+}
+
+func (r *Reloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert, r.clientCAs
+}
+
+// ServerConfig picks up the latest certificate and client CAs on every handshake.
+func (r *Reloader) ServerConfig() *tls.Config {
+	// The config returned for a handshake replaces the one grpc added h2 to.
+	return r.serverConfig("h2")
+}
+
+// HTTPServerConfig is ServerConfig for the HTTP gateway, which requires client
+// certificates from the same CAs as the gRPC listener.
+func (r *Reloader) HTTPServerConfig() *tls.Config {
+	return r.serverConfig("h2", "http/1.1")
+}
+
+func (r *Reloader) serverConfig(nextProtos ...string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, clientCAs := r.current()
+
+			cnf := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				NextProtos:   nextProtos,
+			}
+			if clientCAs != nil {
+				cnf.ClientAuth = tls.RequireAndVerifyClientCert
+				cnf.ClientCAs = clientCAs
+			}
+			return cnf, nil
+		},
+	}
+}
+
+// LoopbackClientConfig is used by the gateway to call this server. It accepts
+// exactly the certificate the server currently presents and, with mutual TLS,
+// presents that certificate as its own. It fails when the certificate isn't fit
+// for that, and Reload rejects such certificates from then on. The gateway asks
+// for LoopbackServerName, so that ClientIdentity doesn't mistake its calls for
+// calls of the server's identity.
+func (r *Reloader) LoopbackClientConfig() (*tls.Config, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := verifyLoopbackClient(r.cert, r.clientCAs); err != nil {
+		return nil, err
+	}
+	r.loopback = true
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: LoopbackServerName,
+		// The certificate is pinned below instead of verified against a CA.
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			cert, _ := r.current()
+			if len(rawCerts) == 0 || !bytes.Equal(rawCerts[0], cert.Certificate[0]) {
+				return errors.New("server certificate doesn't match the loopback certificate")
+			}
+			return nil
+		},
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := r.current()
+			return cert, nil
+		},
+	}, nil
+}
+
+// verifyLoopbackClient checks that the server accepts cert as a client
+// certificate: with mutual TLS, it must be signed by a client CA and allow client
+// authentication.
+func verifyLoopbackClient(cert *tls.Certificate, clientCAs *x509.CertPool) error {
+	if clientCAs == nil {
+		return nil
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, der := range cert.Certificate[1:] {
+		ca, err := x509.ParseCertificate(der)
+		if err != nil {
+			return fmt.Errorf("failed to parse the certificate chain: %w", err)
+		}
+		intermediates.AddCert(ca)
+	}
+
+	_, err := cert.Leaf.Verify(x509.VerifyOptions{
+		Roots:         clientCAs,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return fmt.Errorf("the gateway can't present the server certificate as a client certificate, "+
+			"it must be signed by a client CA and allow client authentication: %w", err)
+	}
+	return nil
+}